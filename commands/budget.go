@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"sync"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/config"
+	"github.com/algo7/tf2_rcon_misc/network"
+)
+
+// budgetWindow is the window the chat budget counts the lines in
+const budgetWindow = time.Minute
+
+// chatBudget limits the chat lines all commands together may say per minute, so players can't get us kicked for spam
+var chatBudget = &budget{limit: config.Default().Commands.ChatBudget}
+
+// budget counts the lines said in the last budgetWindow, a limit of 0 or less allows everything
+type budget struct {
+	mu    sync.Mutex
+	limit int
+	said  []time.Time
+}
+
+// Configure sets the chat budget of the commands
+func Configure(cfg config.Commands) {
+	chatBudget.mu.Lock()
+	defer chatBudget.mu.Unlock()
+
+	chatBudget.limit = cfg.ChatBudget
+}
+
+// left returns how many lines may still be said right now
+func (b *budget) left(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return 1
+	}
+
+	b.expire(now)
+	return b.limit - len(b.said)
+}
+
+// take counts a line if the budget allows it and reports whether it did
+func (b *budget) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return true
+	}
+
+	b.expire(now)
+	if len(b.said) >= b.limit {
+		return false
+	}
+
+	b.said = append(b.said, now)
+	return true
+}
+
+// expire forgets the lines that left the window, the caller holds the lock
+func (b *budget) expire(now time.Time) {
+	i := 0
+	for i < len(b.said) && now.Sub(b.said[i]) >= budgetWindow {
+		i++
+	}
+
+	b.said = b.said[i:]
+}
+
+// reply says the line in chat if the chat budget allows it, all command output goes through here
+func reply(line string) {
+	if !chatBudget.take(time.Now()) {
+		log.Printf("Not saying '%s', the chat budget is used up", line)
+		return
+	}
+
+	network.Say(line, false, network.PriorityLow)
+}
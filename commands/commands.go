@@ -1,35 +1,116 @@
 package commands
 
 import (
+	"fmt"
+	"strings"
 	"time"
-
-	"github.com/algo7/tf2_rcon_misc/network"
 )
 
-// CommandExecuted is a function that executes a given command using the given arguments
-func CommandExecuted(command string, args string, callerName string, currentPlayer string) {
-
-	// If the caller is the current player
-	if callerName == currentPlayer {
-		switch command {
-		case "test":
-			time.Sleep(1000 * time.Millisecond)
-			network.RconExecute("say \"Test command executed!. Value:" + args + "\"")
-		case "roast":
-			time.Sleep(1000 * time.Millisecond)
+// registerBuiltins registers the commands that come with the program
+func registerBuiltins() {
+	Register(Command{
+		Name:     "test",
+		Args:     "[value]",
+		Allow:    AllowEveryone,
+		Cooldown: 10 * time.Second,
+		Help:     "Says the value back",
+		Run: func(args string, caller Caller) {
+			reply("Test command executed!. Value:" + args)
+		},
+	})
+
+	Register(Command{
+		Name:         "roast",
+		Aliases:      []string{"insult"},
+		Args:         "<target>",
+		ArgsRequired: true,
+		Allow:        AllowSelf,
+		Cooldown:     30 * time.Second,
+		Help:         "Insults the target",
+		Run: func(args string, caller Caller) {
 			getInsult(args)
-		default:
+		},
+	})
+
+	Register(Command{
+		Name:         "names",
+		Aliases:      []string{"aka"},
+		Args:         "<player>",
+		ArgsRequired: true,
+		Allow:        AllowEveryone,
+		Cooldown:     10 * time.Second,
+		Help:         "Says the names the player used before",
+		Run: func(args string, caller Caller) {
+			sayNames(args)
+		},
+	})
+
+	Register(Command{
+		Name:     "help",
+		Args:     "[command]",
+		Allow:    AllowEveryone,
+		Cooldown: 30 * time.Second,
+		Help:     "Lists the commands you may use or explains one",
+		Run:      sayHelp,
+	})
+}
+
+// CommandExecuted executes the command typed by the caller if they may run it
+func CommandExecuted(name string, args string, caller Caller) {
+	command, ok := lookup(name)
+	if !ok {
+		return
+	}
+
+	if !command.allows(caller) {
+		log.Printf("Rejected !%s of '%s' (%d), they are not allowed to run it", command.Name, caller.Name, caller.SteamID)
+		return
+	}
+
+	now := time.Now()
+
+	// Don't count the run if there is no room to answer anyway
+	if chatBudget.left(now) <= 0 {
+		log.Printf("Rejected !%s of '%s' (%d), the chat budget is used up", command.Name, caller.Name, caller.SteamID)
+		return
+	}
+
+	if command.coolingDown(caller, now) {
+		log.Printf("Rejected !%s of '%s' (%d), they ran it less than %s ago", command.Name, caller.Name, caller.SteamID, command.Cooldown)
+		return
+	}
+
+	args = strings.TrimSpace(args)
+	if args == "" && command.ArgsRequired {
+		reply("Usage: " + command.Usage())
+		return
+	}
+
+	command.Run(args, caller)
+}
+
+// sayHelp lists the commands the caller may use, or explains the given one.
+// The lines never start with "!", our own chat comes back to the dispatcher.
+func sayHelp(args string, caller Caller) {
+	if args != "" {
+		command, ok := lookup(strings.TrimPrefix(args, "!"))
+		if !ok || !command.allows(caller) {
 			return
 		}
-	}
 
-	// If the caller is not the current player
-	switch command {
-	case "test":
-		time.Sleep(1000 * time.Millisecond)
-		network.RconExecute("say \"Test command executed!. Value:" + args + "\"")
-	default:
+		help := "Usage: " + command.Usage() + " - " + command.Help
+		if len(command.Aliases) > 0 {
+			help += fmt.Sprintf(" (also !%s)", strings.Join(command.Aliases, ", !"))
+		}
+
+		reply(truncate(help, chatLimit))
 		return
 	}
 
+	var usages []string
+	for _, command := range allowedCommands(caller) {
+		usages = append(usages, command.Usage())
+	}
+
+	reply(truncate("Commands: "+strings.Join(usages, ", "), chatLimit))
 }
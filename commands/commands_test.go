@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/config"
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/network/rcontest"
+	"github.com/algo7/tf2_rcon_misc/state"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// TestHandlersEndToEnd runs chat commands through the bus to the RCON server, like they come from the console
+func TestHandlersEndToEnd(t *testing.T) {
+	utils.GrokInit()
+
+	server := rcontest.NewServer("secret", "me")
+	defer server.Close()
+
+	network.Configure(config.Rcon{Host: server.Host(), Port: server.Port(), Password: "secret", DialTimeout: config.Duration(time.Second)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := network.Connect(ctx); err != nil {
+		t.Fatalf("Connect() = %v", err)
+	}
+	defer network.Close()
+
+	registry := state.NewPlayerRegistry()
+	registry.Upsert(&utils.PlayerInfo{SteamID: 76561198000000001, UserID: 2, Name: "someone", LastSeen: time.Now().Unix()})
+	registry.Upsert(&utils.PlayerInfo{SteamID: 76561198000000002, UserID: 3, Name: "me", LastSeen: time.Now().Unix()})
+	registry.Upsert(&utils.PlayerInfo{SteamID: 76561198000000003, UserID: 4, Name: "me", LastSeen: time.Now().Unix()})
+	registry.SetMe(76561198000000003)
+
+	bus := events.NewBus()
+	Subscribe(bus, registry)
+
+	chat := func(name string, steamID int64, message string) {
+		bus.Publish(events.ChatMessage{Chat: &utils.ChatInfo{PlayerName: name, Message: message}, SteamID: steamID})
+	}
+
+	// Only we may roast, the others' commands are answered in the order they came in
+	chat("someone", 76561198000000001, "!roast me")
+	chat("someone", 76561198000000001, "!test hello")
+	chat("someone", 76561198000000001, "!help")
+
+	// Copying our name gives neither our permissions nor our freedom from cooldowns
+	chat("me", 76561198000000002, "!help")
+	chat("me", 76561198000000002, "!test copied")
+	chat("me", 76561198000000002, "!test again")
+	chat("me", 76561198000000003, "!test mine")
+	chat("me", 76561198000000003, "!test mine again")
+
+	said := waitForSays(t, server, 6)
+
+	if said[0] != `say "Test command executed!. Value:hello"` {
+		t.Fatalf("first line = %q, want the answer to !test", said[0])
+	}
+	for _, help := range said[1:3] {
+		if !strings.HasPrefix(help, `say "Commands: `) || strings.Contains(help, "!roast") {
+			t.Fatalf("help = %q, want it without !roast", help)
+		}
+	}
+
+	want := []string{
+		`say "Test command executed!. Value:copied"`,
+		`say "Test command executed!. Value:mine"`,
+		`say "Test command executed!. Value:mine again"`,
+	}
+	for i, line := range want {
+		if said[3+i] != line {
+			t.Fatalf("said %q, want %q after the help", said[3:], want)
+		}
+	}
+}
+
+// waitForSays waits until the server received n chat lines and returns them
+func waitForSays(t *testing.T, server *rcontest.Server, n int) []string {
+	t.Helper()
+
+	var said []string
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		said = said[:0]
+		for _, command := range server.Commands() {
+			if strings.HasPrefix(command, "say ") {
+				said = append(said, command)
+			}
+		}
+
+		if len(said) >= n {
+			return said
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the server received %q, want %d chat lines", said, n)
+	return nil
+}
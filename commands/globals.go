@@ -2,7 +2,11 @@ package commands
 
 import (
 	"github.com/algo7/tf2_rcon_misc/logger"
+	"github.com/algo7/tf2_rcon_misc/state"
 )
 
 // Create a new instance of the logger.
 var log = logger.Logger
+
+// players resolves the names given to commands, it is set by Subscribe
+var players *state.PlayerRegistry
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+const (
+	// namesLimit is the number of names !names reports
+	namesLimit = 8
+	// chatLimit is the number of characters TF2 shows of a chat line
+	chatLimit = 127
+)
+
+// sayNames says the names the given player used before
+func sayNames(query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return
+	}
+
+	player, steamID, err := resolvePlayer(query)
+	if err != nil {
+		// Don't start with "!", our own chat comes back to the dispatcher
+		reply(fmt.Sprintf("Names of %s: %v", query, err))
+		return
+	}
+
+	names, err := db.FindNames(steamID, namesLimit)
+	if err != nil {
+		log.Printf("Error finding the names of %d: %v", steamID, err)
+		return
+	}
+
+	var aliases []string
+	for _, name := range names {
+		aliases = append(aliases, name.Name)
+	}
+
+	reply(truncate(fmt.Sprintf("%s was also known as: %s", player, strings.Join(aliases, ", ")), chatLimit))
+}
+
+// resolvePlayer finds the player on the server whose name matches the query, then the one who used the name before
+func resolvePlayer(query string) (string, int64, error) {
+	if named := players.Named(query); len(named) == 1 {
+		return named[0].Name, named[0].SteamID, nil
+	}
+
+	// Part of the name, as it looks on screen
+	canonical := strings.ToLower(utils.CanonicalName(query))
+	var found []*utils.PlayerInfo
+	for _, player := range players.Snapshot() {
+		if strings.Contains(strings.ToLower(utils.CanonicalName(player.Name)), canonical) {
+			found = append(found, player)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0].Name, found[0].SteamID, nil
+	case len(found) > 1:
+		return "", 0, errors.New("more than one player matches")
+	}
+
+	steamIDs, err := db.FindSteamIDsByName(query)
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch len(steamIDs) {
+	case 0:
+		return "", 0, errors.New("player not found")
+	case 1:
+		return query, steamIDs[0], nil
+	default:
+		return "", 0, errors.New("more than one player used that name")
+	}
+}
+
+// truncate cuts the text to the given number of characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Permission says who may run a command, permissions can be combined with |
+type Permission int
+
+const (
+	// AllowSelf lets the local player run the command
+	AllowSelf Permission = 1 << iota
+	// AllowFriends lets players marked as friend run the command
+	AllowFriends
+	// AllowTrusted lets players marked as trusted run the command
+	AllowTrusted
+	// AllowEveryone lets every player on the server run the command
+	AllowEveryone
+)
+
+// Caller is the player who typed a command in chat
+type Caller struct {
+	Name string
+	// SteamID is 0 if the player could not be resolved
+	SteamID int64
+	// Self is set if the local player typed the command
+	Self bool
+}
+
+// Command is a chat command like `!names <player>`
+type Command struct {
+	Name    string
+	Aliases []string
+	// Args describes the arguments in the help, e.g. "<player>", empty for commands without arguments
+	Args string
+	// ArgsRequired makes the command answer with its usage instead of running without arguments
+	ArgsRequired bool
+	Allow        Permission
+	// Cooldown is the minimum time between two runs by the same player, we are never held back
+	Cooldown time.Duration
+	Help     string
+	Run      func(args string, caller Caller)
+}
+
+// Usage returns how the command is typed, e.g. "!names <player>"
+func (c *Command) Usage() string {
+	if c.Args == "" {
+		return "!" + c.Name
+	}
+
+	return "!" + c.Name + " " + c.Args
+}
+
+var (
+	registryMu sync.RWMutex
+	// registry holds the commands by name and alias
+	registry = make(map[string]*Command)
+	// lastRun holds when a command was last run by a player, by command name and caller, see runKey
+	lastRun = make(map[string]time.Time)
+)
+
+// maxLastRun is the number of tracked runs before the expired ones are cleaned up
+const maxLastRun = 256
+
+// Register adds the command, a command with the same name or alias is replaced
+func Register(command Command) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registered := &command
+	registry[command.Name] = registered
+	for _, alias := range command.Aliases {
+		registry[alias] = registered
+	}
+}
+
+// lookup returns the command with the given name or alias
+func lookup(name string) (*Command, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	command, ok := registry[strings.ToLower(name)]
+	return command, ok
+}
+
+// allowedCommands returns the commands the caller may run, sorted by name
+func allowedCommands(caller Caller) []*Command {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var allowed []*Command
+	for name, command := range registry {
+		// Aliases point to the same command
+		if name == command.Name && command.allows(caller) {
+			allowed = append(allowed, command)
+		}
+	}
+
+	sort.Slice(allowed, func(i, j int) bool {
+		return allowed[i].Name < allowed[j].Name
+	})
+
+	return allowed
+}
+
+// allows reports whether the caller may run the command
+func (c *Command) allows(caller Caller) bool {
+	if c.Allow&AllowEveryone != 0 {
+		return true
+	}
+
+	if caller.Self {
+		return c.Allow&AllowSelf != 0
+	}
+
+	// Friends and trusted players are known by their mark, unresolved players have none
+	if caller.SteamID == 0 || players == nil {
+		return false
+	}
+
+	player, ok := players.LookupBySteamID(caller.SteamID)
+	if !ok {
+		return false
+	}
+
+	return (c.Allow&AllowFriends != 0 && player.Mark.Has(utils.MarkFriend)) ||
+		(c.Allow&AllowTrusted != 0 && player.Mark.Has(utils.MarkTrusted))
+}
+
+// coolingDown reports whether the caller ran the command too recently to run it again, otherwise it counts this run
+func (c *Command) coolingDown(caller Caller, now time.Time) bool {
+	if caller.Self || c.Cooldown <= 0 {
+		return false
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	key := runKey(c.Name, caller)
+	if last, ok := lastRun[key]; ok && now.Sub(last) < c.Cooldown {
+		return true
+	}
+
+	if len(lastRun) >= maxLastRun {
+		expireRuns(now)
+	}
+
+	lastRun[key] = now
+	return false
+}
+
+// runKey is the key of the command run by the caller in lastRun, unresolved players are told apart by name
+func runKey(name string, caller Caller) string {
+	if caller.SteamID == 0 {
+		return name + "\x00" + caller.Name
+	}
+
+	return name + "\x00" + strconv.FormatInt(caller.SteamID, 10)
+}
+
+// expireRuns forgets the runs whose cooldown is over, the caller holds the lock
+func expireRuns(now time.Time) {
+	for key, last := range lastRun {
+		name, _, _ := strings.Cut(key, "\x00")
+		if command, ok := registry[name]; !ok || now.Sub(last) >= command.Cooldown {
+			delete(lastRun, key)
+		}
+	}
+}
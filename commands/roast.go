@@ -2,49 +2,62 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"time"
-
-	"github.com/algo7/tf2_rcon_misc/network"
 )
 
-// getInsult returns an insult for the given target
+// insultURL is the endpoint of the Insult API
+var insultURL = "https://insult.mattbas.org/api/insult.json"
+
+// insultClient calls the Insult API, commands run on the bus so a slow API must not hold it up for long
+var insultClient = &http.Client{Timeout: 5 * time.Second}
+
+// getInsult says an insult for the given target, nothing is said if the Insult API fails
 func getInsult(target string) {
 	log.Println("Getting insult for " + target)
-	// Set up query parameters
-	query := url.Values{}
-	query.Set("plural", "true")
-	query.Set("template", fmt.Sprintf("%s is <article target=adj1> <adjective min=3 max=5 id=adj1> <amount> like <article target=adj2> <adjective min=1 max=3 id=adj2> <adverb><animal>", target))
 
-	// Send GET request to API
-	resp, err := http.Get("https://insult.mattbas.org/api/insult.json?" + query.Encode())
+	insult, err := fetchInsult(target)
 	if err != nil {
 		log.Printf("Error while calling the Insult API: %v", err)
+		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
+	reply(insult)
+	log.Println("Insult: " + insult)
+}
 
-	// Parse response JSON
-	var data map[string]interface{}
+// fetchInsult asks the Insult API for an insult of the target
+func fetchInsult(target string) (string, error) {
+	// Set up query parameters
+	query := url.Values{}
+	query.Set("plural", "true")
+	query.Set("template", fmt.Sprintf("%s is <article target=adj1> <adjective min=3 max=5 id=adj1> <amount> like <article target=adj2> <adjective min=1 max=3 id=adj2> <adverb><animal>", target))
 
-	err = json.NewDecoder(resp.Body).Decode(&data)
+	resp, err := insultClient.Get(insultURL + "?" + query.Encode())
 	if err != nil {
-		log.Printf("Error while decoding the Insult API response: %v", err)
+		return "", err
 	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
 
-	// Extract insult from response data
-	insult, ok := data["insult"].(string)
-	if !ok {
-		log.Println("Error while parsing the Insult API response")
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
 	}
 
-	time.Sleep(1000 * time.Millisecond)
+	var data struct {
+		Insult string `json:"insult"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("unable to decode the response: %w", err)
+	}
 
-	network.RconExecute("say \"" + insult + "\"")
-	log.Println("Insult: " + insult)
+	if data.Insult == "" {
+		return "", errors.New("the response has no insult")
+	}
+
+	return data.Insult, nil
 }
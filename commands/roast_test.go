@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchInsult(t *testing.T) {
+	responses := map[string]struct {
+		status int
+		body   string
+		want   string
+	}{
+		"insult":     {http.StatusOK, `{"insult": "target is a test", "args": {}}`, "target is a test"},
+		"bad status": {http.StatusInternalServerError, `{"insult": "target is a test"}`, ""},
+		"bad json":   {http.StatusOK, `<html>`, ""},
+		"no insult":  {http.StatusOK, `{"error": true}`, ""},
+	}
+
+	defer func(original string) {
+		insultURL = original
+	}(insultURL)
+
+	for name, response := range responses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Query().Get("template"), "target is ") {
+				t.Errorf("%s: the template %q does not insult the target", name, r.URL.Query().Get("template"))
+			}
+			w.WriteHeader(response.status)
+			_, _ = w.Write([]byte(response.body))
+		}))
+		insultURL = server.URL
+
+		got, err := fetchInsult("target")
+		server.Close()
+
+		if got != response.want || (err == nil) != (response.want != "") {
+			t.Errorf("%s: fetchInsult() = %q, %v, want %q", name, got, err, response.want)
+		}
+	}
+
+	// The server is gone, the request fails instead of panicking
+	if got, err := fetchInsult("target"); err == nil {
+		t.Errorf("fetchInsult() = %q without a server, want an error", got)
+	}
+}
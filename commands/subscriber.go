@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/state"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Subscribe registers the command dispatcher on the given bus, playerRegistry holds the players commands can refer to
+// and knows the steamID of the local player
+func Subscribe(bus *events.Bus, playerRegistry *state.PlayerRegistry) {
+	players = playerRegistry
+	registerBuiltins()
+
+	bus.Subscribe(func(e events.Event) {
+		message := e.(events.ChatMessage)
+		chat := message.Chat
+
+		// Parse the chat message for commands
+		if command, args, err := utils.GrokParseCommand(chat.Message); err == nil {
+			// Someone may copy our name, only our steamID tells it is us
+			self := message.SteamID != 0 && message.SteamID == players.MySteamID()
+			caller := Caller{Name: chat.PlayerName, SteamID: message.SteamID, Self: self}
+			CommandExecuted(command, args, caller)
+		}
+	}, events.TypeChatMessage)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings of the program.
+// Values are read from the config file first, then overridden by `TF2_RCON_*` environment variables and finally by command-line flags.
+type Config struct {
+	Rcon      Rcon      `json:"rcon"`
+	Detection Detection `json:"detection"`
+	Database  Database  `json:"database"`
+	Commands  Commands  `json:"commands"`
+}
+
+// Rcon holds the settings for the RCON connection to the game
+type Rcon struct {
+	// Host of the game, the LAN is scanned for an open RCON port if empty
+	Host        string   `json:"host"`
+	Port        int      `json:"port"`
+	Password    string   `json:"password"`
+	DialTimeout Duration `json:"dialTimeout"`
+	// SayInterval is the minimum time between two chat lines, to avoid TF2 chat flood kicks
+	SayInterval Duration `json:"sayInterval"`
+}
+
+// Detection holds the settings of the bot and cheater detection
+type Detection struct {
+	// RulesFile is the JSON file with the detection rules, the built-in rules are used if the default file does not exist
+	RulesFile string `json:"rulesFile"`
+}
+
+// Database holds the settings of the database keeping the history
+type Database struct {
+	// Backend is "file", "mongo" or "none", it defaults to "mongo" if a MongoDB URI is set and "none" otherwise
+	Backend string `json:"backend"`
+	// Path is the directory of the file backend
+	Path string `json:"path"`
+	// URI and Name select the database of the mongo backend
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+// Commands holds the settings of the chat commands
+type Commands struct {
+	// ChatBudget is the number of chat lines all commands together may say per minute, 0 removes the limit
+	ChatBudget int `json:"chatBudget"`
+}
+
+// Duration is a time.Duration that is written as a string like "5s" in the config file
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string like "5s"
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string like "5s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultPath is the config file that is read when none is given
+const defaultPath = "config.json"
+
+// Default returns the config used when nothing is configured
+func Default() *Config {
+	return &Config{
+		Rcon: Rcon{
+			Port:        27015,
+			Password:    "123",
+			DialTimeout: Duration(60 * time.Second),
+			SayInterval: Duration(time.Second),
+		},
+		Database: Database{
+			Path: "data",
+			Name: "TF2",
+		},
+		Commands: Commands{
+			ChatBudget: 10,
+		},
+	}
+}
+
+// Load builds the config from the config file, the environment and the given command-line arguments
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("tf2_rcon_misc", flag.ContinueOnError)
+	path := flags.String("config", envOr("TF2_RCON_CONFIG", defaultPath), "path to the JSON config file")
+	host := flags.String("rcon-host", "", "RCON host, the LAN is scanned if empty")
+	port := flags.Int("rcon-port", 0, "RCON port")
+	password := flags.String("rcon-password", "", "RCON password")
+	dialTimeout := flags.Duration("rcon-dial-timeout", 0, "timeout for connecting to RCON")
+	sayInterval := flags.Duration("rcon-say-interval", 0, "minimum time between two chat lines")
+	rulesFile := flags.String("rules", "", "path to the JSON detection rules file")
+	dbBackend := flags.String("db-backend", "", "database backend: file, mongo or none")
+	dbPath := flags.String("db-path", "", "directory of the file database")
+	chatBudget := flags.Int("chat-budget", 0, "chat lines all commands together may say per minute, 0 for no limit")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// The default config file is optional, an explicitly given one is not
+	if err := cfg.readFile(*path); err != nil && !(errors.Is(err, os.ErrNotExist) && *path == defaultPath) {
+		return nil, fmt.Errorf("unable to read config file %s: %w", *path, err)
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags that were actually given override the config
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "rcon-host":
+			cfg.Rcon.Host = *host
+		case "rcon-port":
+			cfg.Rcon.Port = *port
+		case "rcon-password":
+			cfg.Rcon.Password = *password
+		case "rcon-dial-timeout":
+			cfg.Rcon.DialTimeout = Duration(*dialTimeout)
+		case "rcon-say-interval":
+			cfg.Rcon.SayInterval = Duration(*sayInterval)
+		case "rules":
+			cfg.Detection.RulesFile = *rulesFile
+		case "db-backend":
+			cfg.Database.Backend = *dbBackend
+		case "db-path":
+			cfg.Database.Path = *dbPath
+		case "chat-budget":
+			cfg.Commands.ChatBudget = *chatBudget
+		}
+	})
+
+	// Existing setups only configured MongoDB, without it the database stays disabled like before
+	if cfg.Database.Backend == "" {
+		cfg.Database.Backend = "none"
+		if cfg.Database.URI != "" {
+			cfg.Database.Backend = "mongo"
+		}
+	}
+
+	return cfg, nil
+}
+
+// readFile reads the JSON config file at path into the config
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+// readEnv reads the `TF2_RCON_*` and `MONGODB_*` environment variables into the config
+func (c *Config) readEnv() error {
+	if host := os.Getenv("TF2_RCON_HOST"); host != "" {
+		c.Rcon.Host = host
+	}
+
+	if port := os.Getenv("TF2_RCON_PORT"); port != "" {
+		parsed, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid TF2_RCON_PORT: %w", err)
+		}
+		c.Rcon.Port = parsed
+	}
+
+	if password := os.Getenv("TF2_RCON_PASSWORD"); password != "" {
+		c.Rcon.Password = password
+	}
+
+	if timeout := os.Getenv("TF2_RCON_DIAL_TIMEOUT"); timeout != "" {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("invalid TF2_RCON_DIAL_TIMEOUT: %w", err)
+		}
+		c.Rcon.DialTimeout = Duration(parsed)
+	}
+
+	if interval := os.Getenv("TF2_RCON_SAY_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid TF2_RCON_SAY_INTERVAL: %w", err)
+		}
+		c.Rcon.SayInterval = Duration(parsed)
+	}
+
+	if rulesFile := os.Getenv("TF2_RCON_RULES"); rulesFile != "" {
+		c.Detection.RulesFile = rulesFile
+	}
+
+	if backend := os.Getenv("TF2_RCON_DB_BACKEND"); backend != "" {
+		c.Database.Backend = backend
+	}
+
+	if path := os.Getenv("TF2_RCON_DB_PATH"); path != "" {
+		c.Database.Path = path
+	}
+
+	if budget := os.Getenv("TF2_RCON_CHAT_BUDGET"); budget != "" {
+		parsed, err := strconv.Atoi(budget)
+		if err != nil {
+			return fmt.Errorf("invalid TF2_RCON_CHAT_BUDGET: %w", err)
+		}
+		c.Commands.ChatBudget = parsed
+	}
+
+	// The MongoDB variables predate the config file
+	if uri := os.Getenv("MONGODB_URI"); uri != "" {
+		c.Database.URI = uri
+	}
+
+	if name := os.Getenv("MONGODB_NAME"); name != "" {
+		c.Database.Name = name
+	}
+
+	return nil
+}
+
+// envOr returns the environment variable or the fallback if it is not set
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestDatabaseBackendDefault(t *testing.T) {
+	t.Setenv("TF2_RCON_CONFIG", "")
+	t.Setenv("TF2_RCON_DB_BACKEND", "")
+
+	cases := map[string]string{
+		"":                          "none",
+		"mongodb://localhost:27017": "mongo",
+	}
+
+	for uri, want := range cases {
+		t.Setenv("MONGODB_URI", uri)
+
+		cfg, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.Backend != want {
+			t.Errorf("with MONGODB_URI %q the backend is %q, want %q", uri, cfg.Database.Backend, want)
+		}
+	}
+
+	t.Setenv("MONGODB_URI", "")
+	cfg, err := Load([]string{"-db-backend", "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Backend != "file" {
+		t.Errorf("the -db-backend flag was ignored, the backend is %q", cfg.Database.Backend)
+	}
+}
@@ -1,52 +1,87 @@
 package db
 
 import (
-	"context"
-	"os"
+	"fmt"
+)
 
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
+// Backends that can keep the history
+const (
+	// BackendMongo keeps everything in MongoDB
+	BackendMongo = "mongo"
+	// BackendFile keeps everything in JSON files in a local directory, no setup needed
+	BackendFile = "file"
+	// BackendNone disables the database
+	BackendNone = "none"
 )
 
-// connect to the database
-func connect() *mongo.Client {
-	log.Println("Connecting to MongoDB...")
+// Store keeps the players, chats, frags, sessions, marks, names and encounters
+type Store interface {
+	// The batch writes are fed by the writer, see writer.go
+	AddPlayers(players []Player) error
+	AddChats(chats []Chat) error
+	AddFrags(frags []Frag) error
+	UpsertSessions(sessions []Session) error
+	AddNames(names []Name) error
+	AddEncounters(encounters []Encounter) error
 
-	// Get the MongoDB URI from the environment
-	mongoURI := os.Getenv("MONGODB_URI")
+	// Marks are written right away, they are typed in by hand
+	SetMark(mark Mark) error
 
-	// If the URI is empty, use the default
-	if mongoURI == "" {
-		log.Println("MongoDB not set, disabling database support.")
-		return nil
-	}
+	FindChats(steamID int64, limit int64) ([]Chat, error)
+	FindFrags(steamID int64, limit int64) ([]Frag, error)
+	FindMarks() ([]Mark, error)
+	FindNames(steamID int64, limit int64) ([]Name, error)
+	FindSteamIDsByName(name string) ([]int64, error)
+	FindEncounters(steamIDs []int64, excludeSessionID string) ([]EncounterSummary, error)
+
+	Close() error
+}
 
-	// Use the SetServerAPIOptions() method to set the Stable API version to 1
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+// Options selects and configures the backend
+type Options struct {
+	Backend string
+	// Path is the directory of the file backend
+	Path string
+	// URI and Name select the database of the MongoDB backend
+	URI  string
+	Name string
+}
 
-	// Set client options
-	clientOptions := options.Client().ApplyURI(mongoURI).SetServerAPIOptions(serverAPI)
+// Open opens the store of the configured backend, all functions of the package use it from then on
+func Open(opts Options) error {
+	var err error
 
-	// Connect to MongoDB
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	switch opts.Backend {
+	case BackendMongo:
+		store, err = openMongo(opts.URI, opts.Name)
+	case BackendFile:
+		store, err = openFile(opts.Path)
+	case BackendNone:
+		log.Println("Database support is disabled.")
+		return nil
+	default:
+		return fmt.Errorf("unknown database backend %q", opts.Backend)
+	}
 
 	if err != nil {
-		log.Fatalf("Unable to connect to MongoDB: %v", err)
+		store = nil
+		return fmt.Errorf("unable to open the %s database: %w", opts.Backend, err)
 	}
 
-	// defer func() {
-	// 	if err = client.Disconnect(context.TODO()); err != nil {
-	// 		utils.ErrorHandler(err)
-	// 	}
-	// }()
+	queue = newWriter(store)
+	return nil
+}
 
-	// Send a ping to confirm a successful connection
-	if err := client.Ping(context.TODO(), readpref.Primary()); err != nil {
-		log.Fatalf("Unable to ping MongoDB: %v", err)
+// Close writes the queued documents and closes the store, the functions of the package are disabled afterwards
+func Close() error {
+	if store == nil {
+		return nil
 	}
 
-	log.Println("Connected to MongoDB!")
+	queue.close()
+	queue = nil
 
-	return client
+	err := store.Close()
+	store = nil
+	return err
 }
@@ -0,0 +1,585 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flushInterval is how often the file backend writes changed documents to disk
+const flushInterval = 30 * time.Second
+
+// Files of the file backend. Chats and frags are only ever appended, one JSON document per line.
+// Everything else is updated in place and written as a whole whenever it changed.
+const (
+	chatsFile      = "chats.jsonl"
+	fragsFile      = "frags.jsonl"
+	playersFile    = "players.json"
+	sessionsFile   = "sessions.json"
+	marksFile      = "marks.json"
+	namesFile      = "names.json"
+	encountersFile = "encounters.json"
+)
+
+// fileStore keeps everything in memory and in JSON files in a directory, it needs no setup
+type fileStore struct {
+	dir string
+
+	mu         sync.RWMutex
+	chats      []Chat
+	frags      []Frag
+	players    map[int64]Player
+	sessions   map[string]Session
+	marks      map[int64]Mark
+	names      map[string]Name
+	encounters map[string]Encounter
+	// dirty holds the files of changed documents until the next flush
+	dirty map[string]bool
+
+	chatsOut *os.File
+	fragsOut *os.File
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// openFile loads the documents from the directory at path, it is created if missing
+func openFile(path string) (*fileStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &fileStore{
+		dir:        path,
+		players:    make(map[int64]Player),
+		sessions:   make(map[string]Session),
+		marks:      make(map[int64]Mark),
+		names:      make(map[string]Name),
+		encounters: make(map[string]Encounter),
+		dirty:      make(map[string]bool),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if s.chatsOut, err = s.openAppend(chatsFile); err != nil {
+		return nil, err
+	}
+	if s.fragsOut, err = s.openAppend(fragsFile); err != nil {
+		_ = s.chatsOut.Close()
+		return nil, err
+	}
+
+	go s.flushLoop()
+
+	log.Printf("Using the database in '%s'", path)
+
+	return s, nil
+}
+
+// load reads all files of the directory, missing files are empty
+func (s *fileStore) load() error {
+	err := readLines(s.path(chatsFile), func(line []byte) error {
+		var chat Chat
+		if err := json.Unmarshal(line, &chat); err != nil {
+			return err
+		}
+		s.chats = append(s.chats, chat)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readLines(s.path(fragsFile), func(line []byte) error {
+		var frag Frag
+		if err := json.Unmarshal(line, &frag); err != nil {
+			return err
+		}
+		s.frags = append(s.frags, frag)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var players []Player
+	var sessions []Session
+	var marks []Mark
+	var names []Name
+	var encounters []Encounter
+
+	for file, documents := range map[string]interface{}{
+		playersFile:    &players,
+		sessionsFile:   &sessions,
+		marksFile:      &marks,
+		namesFile:      &names,
+		encountersFile: &encounters,
+	} {
+		if err := readJSON(s.path(file), documents); err != nil {
+			return err
+		}
+	}
+
+	for _, player := range players {
+		s.players[player.SteamID] = player
+	}
+	for _, session := range sessions {
+		s.sessions[session.SessionID] = session
+	}
+	for _, mark := range marks {
+		s.marks[mark.SteamID] = mark
+	}
+	for _, name := range names {
+		s.names[documentKey(name.SteamID, name.Name)] = name
+	}
+	for _, encounter := range encounters {
+		s.encounters[documentKey(encounter.SteamID, encounter.SessionID)] = encounter
+	}
+
+	return nil
+}
+
+// AddPlayers adds the players or updates their names
+func (s *fileStore) AddPlayers(players []Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, player := range players {
+		s.players[player.SteamID] = player
+	}
+	s.dirty[playersFile] = true
+	return nil
+}
+
+// AddChats appends the chat messages
+func (s *fileStore) AddChats(chats []Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, chat := range chats {
+		if err := appendLine(s.chatsOut, chat); err != nil {
+			return err
+		}
+		s.chats = append(s.chats, chat)
+	}
+	return nil
+}
+
+// AddFrags appends the frags
+func (s *fileStore) AddFrags(frags []Frag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, frag := range frags {
+		if err := appendLine(s.fragsOut, frag); err != nil {
+			return err
+		}
+		s.frags = append(s.frags, frag)
+	}
+	return nil
+}
+
+// UpsertSessions adds the server sessions or updates them if they already exist
+func (s *fileStore) UpsertSessions(sessions []Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range sessions {
+		s.sessions[session.SessionID] = session
+	}
+	s.dirty[sessionsFile] = true
+	return nil
+}
+
+// SetMark stores the mark of a player right away, a mark without attributes and notes is removed
+func (s *fileStore) SetMark(mark Mark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(mark.Attributes) == 0 && mark.Notes == "" {
+		delete(s.marks, mark.SteamID)
+	} else {
+		s.marks[mark.SteamID] = mark
+	}
+
+	// Marks are typed in by hand, don't risk losing them
+	return s.writeMarks()
+}
+
+// AddNames records that the players used the names, the first sighting of a name is kept
+func (s *fileStore) AddNames(names []Name) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		key := documentKey(name.SteamID, name.Name)
+		if existing, ok := s.names[key]; ok && existing.FirstSeen < name.FirstSeen {
+			name.FirstSeen = existing.FirstSeen
+		}
+
+		s.names[key] = name
+	}
+	s.dirty[namesFile] = true
+	return nil
+}
+
+// AddEncounters records that we shared the server sessions with the players, the first sighting is kept
+func (s *fileStore) AddEncounters(encounters []Encounter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, encounter := range encounters {
+		key := documentKey(encounter.SteamID, encounter.SessionID)
+		if existing, ok := s.encounters[key]; ok && existing.FirstSeen < encounter.FirstSeen {
+			encounter.FirstSeen = existing.FirstSeen
+		}
+
+		s.encounters[key] = encounter
+	}
+	s.dirty[encountersFile] = true
+	return nil
+}
+
+// FindChats returns the latest chat messages of the player, newest first
+func (s *fileStore) FindChats(steamID int64, limit int64) ([]Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chats []Chat
+	for i := len(s.chats) - 1; i >= 0 && !reached(len(chats), limit); i-- {
+		if s.chats[i].SteamID == steamID {
+			chats = append(chats, s.chats[i])
+		}
+	}
+
+	return chats, nil
+}
+
+// FindFrags returns the latest frags the player was killer or victim of, newest first
+func (s *fileStore) FindFrags(steamID int64, limit int64) ([]Frag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var frags []Frag
+	for i := len(s.frags) - 1; i >= 0 && !reached(len(frags), limit); i-- {
+		if s.frags[i].KillerSteamID == steamID || s.frags[i].VictimSteamID == steamID {
+			frags = append(frags, s.frags[i])
+		}
+	}
+
+	return frags, nil
+}
+
+// FindMarks returns the marks of all players
+func (s *fileStore) FindMarks() ([]Mark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	marks := make([]Mark, 0, len(s.marks))
+	for _, mark := range s.marks {
+		marks = append(marks, mark)
+	}
+
+	return marks, nil
+}
+
+// FindNames returns the names the player used, most recently used first
+func (s *fileStore) FindNames(steamID int64, limit int64) ([]Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []Name
+	for _, name := range s.names {
+		if name.SteamID == steamID {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].LastSeen > names[j].LastSeen
+	})
+
+	if limit > 0 && int64(len(names)) > limit {
+		names = names[:limit]
+	}
+
+	return names, nil
+}
+
+// FindSteamIDsByName returns the steamID64s of all players that ever used the name, ignoring case
+func (s *fileStore) FindSteamIDsByName(name string) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[int64]bool)
+	var steamIDs []int64
+	for _, used := range s.names {
+		if strings.EqualFold(used.Name, name) && !seen[used.SteamID] {
+			seen[used.SteamID] = true
+			steamIDs = append(steamIDs, used.SteamID)
+		}
+	}
+
+	return steamIDs, nil
+}
+
+// FindEncounters sums up the encounters with the given players, the session excludeSessionID is left out
+func (s *fileStore) FindEncounters(steamIDs []int64, excludeSessionID string) ([]EncounterSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summaries := make(map[int64]*EncounterSummary)
+	for _, steamID := range steamIDs {
+		summaries[steamID] = &EncounterSummary{SteamID: steamID}
+	}
+
+	for _, encounter := range s.encounters {
+		summary, ok := summaries[encounter.SteamID]
+		if !ok || encounter.SessionID == excludeSessionID {
+			continue
+		}
+
+		if summary.Sessions == 0 || encounter.FirstSeen < summary.FirstSeen {
+			summary.FirstSeen = encounter.FirstSeen
+		}
+		if encounter.LastSeen > summary.LastSeen {
+			summary.LastSeen = encounter.LastSeen
+		}
+
+		summary.Sessions++
+		summary.Seconds += encounter.LastSeen - encounter.FirstSeen
+		if !containsString(summary.Servers, encounter.Server) {
+			summary.Servers = append(summary.Servers, encounter.Server)
+		}
+	}
+
+	// Like the MongoDB backend, players we never met are left out
+	var result []EncounterSummary
+	for _, steamID := range steamIDs {
+		if summary := summaries[steamID]; summary.Sessions > 0 {
+			result = append(result, *summary)
+		}
+	}
+
+	return result, nil
+}
+
+// Close writes all changes and closes the files
+func (s *fileStore) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.flush()
+	if closeErr := s.chatsOut.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := s.fragsOut.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// flushLoop writes the changed documents every flushInterval until the store is closed
+func (s *fileStore) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.flush(); err != nil {
+				log.Printf("Error writing the database files: %v", err)
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush writes all files with changed documents, the caller holds the lock
+func (s *fileStore) flush() error {
+	for file := range s.dirty {
+		var err error
+
+		switch file {
+		case playersFile:
+			players := make([]Player, 0, len(s.players))
+			for _, player := range s.players {
+				players = append(players, player)
+			}
+			err = writeJSON(s.path(file), players)
+		case sessionsFile:
+			sessions := make([]Session, 0, len(s.sessions))
+			for _, session := range s.sessions {
+				sessions = append(sessions, session)
+			}
+			err = writeJSON(s.path(file), sessions)
+		case namesFile:
+			names := make([]Name, 0, len(s.names))
+			for _, name := range s.names {
+				names = append(names, name)
+			}
+			err = writeJSON(s.path(file), names)
+		case encountersFile:
+			encounters := make([]Encounter, 0, len(s.encounters))
+			for _, encounter := range s.encounters {
+				encounters = append(encounters, encounter)
+			}
+			err = writeJSON(s.path(file), encounters)
+		}
+
+		if err != nil {
+			return err
+		}
+		delete(s.dirty, file)
+	}
+
+	return nil
+}
+
+// writeMarks writes all marks, the caller holds the lock
+func (s *fileStore) writeMarks() error {
+	marks := make([]Mark, 0, len(s.marks))
+	for _, mark := range s.marks {
+		marks = append(marks, mark)
+	}
+
+	return writeJSON(s.path(marksFile), marks)
+}
+
+// path returns the path of the file in the directory of the store
+func (s *fileStore) path(file string) string {
+	return filepath.Join(s.dir, file)
+}
+
+// openAppend opens the file in the directory of the store for appending lines.
+// A line cut off at the end is terminated, so the next line is not appended to it.
+func (s *fileStore) openAppend(file string) (*os.File, error) {
+	out, err := os.OpenFile(s.path(file), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := out.Stat()
+	if err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err = out.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			_, err = out.Write([]byte{'\n'})
+		}
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// documentKey joins the steamID64 and a name or session ID into a map key
+func documentKey(steamID int64, name string) string {
+	return strconv.FormatInt(steamID, 10) + "/" + name
+}
+
+// reached reports whether count results satisfy the limit, limits below 1 mean no limit
+func reached(count int, limit int64) bool {
+	return limit > 0 && int64(count) >= limit
+}
+
+// containsString reports whether the value is in the list
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendLine writes the document as a single line of JSON
+func appendLine(file *os.File, document interface{}) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// readLines calls handle for every non-empty line of the file, a missing file has no lines.
+// Lines handle fails on, like one cut off by a crash while it was appended, are logged and skipped.
+func readLines(path string, handle func(line []byte) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for number := 1; scanner.Scan(); number++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		if err := handle(scanner.Bytes()); err != nil {
+			log.Printf("Skipping line %d of '%s': %v", number, path, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readJSON decodes the file into documents, a missing file leaves them untouched
+func readJSON(path string, documents interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, documents)
+}
+
+// writeJSON replaces the file with the documents, through a temporary file so a crash never leaves half of it
+func writeJSON(path string, documents interface{}) error {
+	data, err := json.Marshal(documents)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
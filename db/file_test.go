@@ -0,0 +1,53 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFileSkipsBrokenLines(t *testing.T) {
+	dir := t.TempDir()
+
+	// The last line was cut off while it was appended
+	chats := `{"SteamID":1,"Name":"first","Message":"gg","SessionID":"a","UpdatedAt":1}
+not json
+{"SteamID":1,"Name":"first","Message":"wp","SessionID":"a","UpdatedAt":2}
+{"SteamID":1,"Name":"fir`
+	if err := os.WriteFile(filepath.Join(dir, chatsFile), []byte(chats), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, fragsFile), []byte("{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openFile(dir)
+	if err != nil {
+		t.Fatalf("openFile() failed on broken lines: %v", err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+
+	found, err := s.FindChats(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("got %d chats, want the 2 valid ones", len(found))
+	}
+
+	// A new line must not be appended to the one that was cut off
+	if err := s.AddChats([]Chat{{SteamID: 1, Name: "first", Message: "again", SessionID: "b", UpdatedAt: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	s, err = openFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := s.FindChats(1, 10); len(found) != 3 {
+		t.Fatalf("got %d chats after reopening, want 3", len(found))
+	}
+}
@@ -1,32 +1,99 @@
 package db
 
 import (
+	"errors"
+
 	"github.com/algo7/tf2_rcon_misc/logger"
-	"os"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Create a new instance of the logger.
 var log = logger.Logger
 
-// Connect to the DB
-var client = connect()
+// store is the opened backend, nil while the database is disabled
+var store Store
 
-var (
-	// Database  name
-	mongoDBName = os.Getenv("MONGODB_NAME")
-)
+// ErrDisabled is returned when no database is configured
+var ErrDisabled = errors.New("database support is disabled")
 
 // Player document struct
 type Player struct {
-	SteamID   int64  `bson:"SteamID"`
-	Name      string `bson:"Name"`
-	UpdatedAt int64  `bson:"UpdatedAt"`
+	SteamID       int64  `bson:"SteamID"`
+	Name          string `bson:"Name"`
+	LastSessionID string `bson:"LastSessionID"`
+	UpdatedAt     int64  `bson:"UpdatedAt"`
 }
 
-// Chat document struct
+// Chat document struct, the ID is set when the chat is queued so a retried write does not add it twice
 type Chat struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	SteamID   int64              `bson:"SteamID"`
+	Name      string             `bson:"Name"`
+	Message   string             `bson:"Message,omitempty"`
+	SessionID string             `bson:"SessionID"`
+	UpdatedAt int64              `bson:"UpdatedAt"`
+}
+
+// Frag document struct, the ID is set when the frag is queued so a retried write does not add it twice
+type Frag struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	KillerSteamID int64              `bson:"KillerSteamID"`
+	VictimSteamID int64              `bson:"VictimSteamID"`
+	KillerName    string             `bson:"KillerName"`
+	VictimName    string             `bson:"VictimName"`
+	Weapon        string             `bson:"Weapon"`
+	Crit          bool               `bson:"Crit"`
+	Map           string             `bson:"Map"`
+	SessionID     string             `bson:"SessionID"`
+	UpdatedAt     int64              `bson:"UpdatedAt"`
+}
+
+// Session document struct
+type Session struct {
+	SessionID string   `bson:"SessionID"`
+	Address   string   `bson:"Address"`
+	Hostname  string   `bson:"Hostname"`
+	Map       string   `bson:"Map"`
+	Tags      []string `bson:"Tags"`
+	SteamID   int64    `bson:"SteamID"`
+	StartedAt int64    `bson:"StartedAt"`
+	EndedAt   int64    `bson:"EndedAt"`
+}
+
+// Mark document struct
+type Mark struct {
+	SteamID    int64    `bson:"SteamID"`
+	Attributes []string `bson:"Attributes"`
+	Notes      string   `bson:"Notes"`
+	MarkedAt   int64    `bson:"MarkedAt"`
+}
+
+// Name document struct, one per name a player used, FirstSeen and LastSeen are unix timestamps
+type Name struct {
 	SteamID   int64  `bson:"SteamID"`
 	Name      string `bson:"Name"`
-	Message   string `bson:"message,omitempty"`
-	UpdatedAt int64  `bson:"updatedAt"`
+	FirstSeen int64  `bson:"FirstSeen"`
+	LastSeen  int64  `bson:"LastSeen"`
+	Server    string `bson:"Server"`
+	Hostname  string `bson:"Hostname"`
+}
+
+// Encounter document struct, one per player and server session we shared, FirstSeen and LastSeen are unix timestamps
+type Encounter struct {
+	SteamID   int64  `bson:"SteamID"`
+	SessionID string `bson:"SessionID"`
+	Server    string `bson:"Server"`
+	Hostname  string `bson:"Hostname"`
+	FirstSeen int64  `bson:"FirstSeen"`
+	LastSeen  int64  `bson:"LastSeen"`
+}
+
+// EncounterSummary sums up all encounters with a player
+type EncounterSummary struct {
+	SteamID   int64    `bson:"_id"`
+	Sessions  int      `bson:"Sessions"`
+	Seconds   int64    `bson:"Seconds"`
+	FirstSeen int64    `bson:"FirstSeen"`
+	LastSeen  int64    `bson:"LastSeen"`
+	Servers   []string `bson:"Servers"`
 }
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongoStore keeps everything in a MongoDB database, one collection per document type
+type mongoStore struct {
+	client *mongo.Client
+	name   string
+}
+
+// openMongo connects to the MongoDB at uri and uses the database with the given name
+func openMongo(uri string, name string) (*mongoStore, error) {
+	log.Println("Connecting to MongoDB...")
+
+	// Use the SetServerAPIOptions() method to set the Stable API version to 1
+	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+
+	// Set client options
+	clientOptions := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.TODO(), clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send a ping to confirm a successful connection
+	if err := client.Ping(context.TODO(), readpref.Primary()); err != nil {
+		_ = client.Disconnect(context.TODO())
+		return nil, err
+	}
+
+	log.Println("Connected to MongoDB!")
+
+	return &mongoStore{client: client, name: name}, nil
+}
+
+// Close disconnects from MongoDB
+func (s *mongoStore) Close() error {
+	return s.client.Disconnect(context.TODO())
+}
@@ -2,83 +2,305 @@ package db
 
 import (
 	"context"
+	"sync"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// AddPlayer adds a player to the database
-func AddPlayer(player Player) *mongo.UpdateResult {
+// writeTimeout is how long a single write to MongoDB may take
+const writeTimeout = 10 * time.Second
 
-	// Check if database is enabled.
-	if client == nil {
-		return nil
+// AddPlayers adds the players to the database or updates their names
+func (s *mongoStore) AddPlayers(players []Player) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Players")
+
+	models := make([]mongo.WriteModel, 0, len(players))
+	for _, player := range players {
+		// Filter by the steamID (64)
+		filter := bson.D{{Key: "SteamID", Value: player.SteamID}}
+
+		// The information to be updated
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "SteamID", Value: player.SteamID},
+			{Key: "Name", Value: player.Name},
+			{Key: "LastSessionID", Value: player.LastSessionID},
+			{Key: "UpdatedAt", Value: player.UpdatedAt},
+		}}}
+
+		// Upsert the document if it doesn't exist
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	return bulkWrite(collection, models)
+}
+
+// AddChats adds the chat messages to the database
+func (s *mongoStore) AddChats(chats []Chat) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Chats")
+
+	models := make([]mongo.WriteModel, 0, len(chats))
+	for _, chat := range chats {
+		// The information to be inserted
+		insert := bson.D{
+			{Key: "SteamID", Value: chat.SteamID},
+			{Key: "Name", Value: chat.Name},
+			{Key: "Message", Value: chat.Message},
+			{Key: "SessionID", Value: chat.SessionID},
+			{Key: "UpdatedAt", Value: chat.UpdatedAt},
+		}
+
+		models = append(models, insertOnce(chat.ID, insert))
+	}
+
+	return bulkWrite(collection, models)
+}
+
+// fragIndexesOnce makes sure the frag indexes are only created once per run
+var fragIndexesOnce sync.Once
+
+// AddFrags adds the frags to the database
+func (s *mongoStore) AddFrags(frags []Frag) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Frags")
+
+	fragIndexesOnce.Do(func() {
+		ensureFragIndexes(collection)
+	})
+
+	models := make([]mongo.WriteModel, 0, len(frags))
+	for _, frag := range frags {
+		// The information to be inserted
+		insert := bson.D{
+			{Key: "KillerSteamID", Value: frag.KillerSteamID},
+			{Key: "VictimSteamID", Value: frag.VictimSteamID},
+			{Key: "KillerName", Value: frag.KillerName},
+			{Key: "VictimName", Value: frag.VictimName},
+			{Key: "Weapon", Value: frag.Weapon},
+			{Key: "Crit", Value: frag.Crit},
+			{Key: "Map", Value: frag.Map},
+			{Key: "SessionID", Value: frag.SessionID},
+			{Key: "UpdatedAt", Value: frag.UpdatedAt},
+		}
+
+		models = append(models, insertOnce(frag.ID, insert))
+	}
+
+	return bulkWrite(collection, models)
+}
+
+// ensureFragIndexes creates the indexes for per-player and per-weapon lookups on the frags collection
+func ensureFragIndexes(collection *mongo.Collection) {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "KillerSteamID", Value: 1}, {Key: "UpdatedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "VictimSteamID", Value: 1}, {Key: "UpdatedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "Weapon", Value: 1}, {Key: "KillerSteamID", Value: 1}}},
 	}
 
-	// If the URI is empty, use the default
-	if mongoDBName == "" {
-		mongoDBName = "TF2"
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Error creating frag indexes in the DB: %v", err)
+	}
+}
+
+// UpsertSessions adds the server sessions to the database or updates them if they already exist
+func (s *mongoStore) UpsertSessions(sessions []Session) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Sessions")
+
+	models := make([]mongo.WriteModel, 0, len(sessions))
+	for _, session := range sessions {
+		// Filter by the session ID
+		filter := bson.D{{Key: "SessionID", Value: session.SessionID}}
+
+		// The information to be updated
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "SessionID", Value: session.SessionID},
+			{Key: "Address", Value: session.Address},
+			{Key: "Hostname", Value: session.Hostname},
+			{Key: "Map", Value: session.Map},
+			{Key: "Tags", Value: session.Tags},
+			{Key: "SteamID", Value: session.SteamID},
+			{Key: "StartedAt", Value: session.StartedAt},
+			{Key: "EndedAt", Value: session.EndedAt},
+		}}}
+
+		// Upsert the document if it doesn't exist
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
 	}
 
+	return bulkWrite(collection, models)
+}
+
+// SetMark stores the mark of a player, a mark without attributes and notes is removed
+func (s *mongoStore) SetMark(mark Mark) error {
+
 	// Get a handle for your collection
-	collection := client.Database(mongoDBName).Collection("Players")
+	collection := s.client.Database(s.name).Collection("Marks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
 
 	// Filter by the steamID (64)
-	filter := bson.D{{Key: "SteamID", Value: player.SteamID}}
+	filter := bson.D{{Key: "SteamID", Value: mark.SteamID}}
+
+	if len(mark.Attributes) == 0 && mark.Notes == "" {
+		_, err := collection.DeleteOne(ctx, filter)
+		return err
+	}
 
 	// The information to be updated
 	update := bson.D{{Key: "$set", Value: bson.D{
-		{Key: "SteamID", Value: player.SteamID},
-		{Key: "Name", Value: player.Name},
-		{Key: "UpdatedAt", Value: player.UpdatedAt},
+		{Key: "SteamID", Value: mark.SteamID},
+		{Key: "Attributes", Value: mark.Attributes},
+		{Key: "Notes", Value: mark.Notes},
+		{Key: "MarkedAt", Value: mark.MarkedAt},
 	}}}
 
 	// Upsert the document if it doesn't exist
 	opts := options.Update().SetUpsert(true)
 
-	// Update the document
-	result, err := collection.UpdateOne(context.TODO(), filter, update, opts)
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+
+	return err
+}
+
+// nameIndexesOnce makes sure the name indexes are only created once per run
+var nameIndexesOnce sync.Once
+
+// AddNames records that the players used the names, the first sighting of a name is kept
+func (s *mongoStore) AddNames(names []Name) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Names")
+
+	nameIndexesOnce.Do(func() {
+		ensureNameIndexes(collection)
+	})
+
+	models := make([]mongo.WriteModel, 0, len(names))
+	for _, name := range names {
+		// Filter by the steamID (64) and the name
+		filter := bson.D{{Key: "SteamID", Value: name.SteamID}, {Key: "Name", Value: name.Name}}
+
+		// The information to be updated
+		update := bson.D{
+			{Key: "$min", Value: bson.D{
+				{Key: "FirstSeen", Value: name.FirstSeen},
+			}},
+			{Key: "$set", Value: bson.D{
+				{Key: "LastSeen", Value: name.LastSeen},
+				{Key: "Server", Value: name.Server},
+				{Key: "Hostname", Value: name.Hostname},
+			}},
+		}
+
+		// Upsert the document if it doesn't exist
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	return bulkWrite(collection, models)
+}
 
-	if err != nil {
-		log.Printf("Error adding player to the DB: %v", err)
+// ensureNameIndexes creates the indexes for looking up the names of a player and the players of a name
+func ensureNameIndexes(collection *mongo.Collection) {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "SteamID", Value: 1}, {Key: "Name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "SteamID", Value: 1}, {Key: "LastSeen", Value: -1}}},
+		{Keys: bson.D{{Key: "Name", Value: 1}}},
 	}
 
-	// log.Printf("Number of documents upserted: %v\n", result)
-	return result
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
 
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Error creating name indexes in the DB: %v", err)
+	}
 }
 
-// AddChat adds a chat message to the database
-func AddChat(chat Chat) *mongo.InsertOneResult {
+// encounterIndexesOnce makes sure the encounter indexes are only created once per run
+var encounterIndexesOnce sync.Once
 
-	// Check if database is enabled.
-	if client == nil {
-		return nil
+// AddEncounters records that we shared the server sessions with the players, the first sighting is kept
+func (s *mongoStore) AddEncounters(encounters []Encounter) error {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Encounters")
+
+	encounterIndexesOnce.Do(func() {
+		ensureEncounterIndexes(collection)
+	})
+
+	models := make([]mongo.WriteModel, 0, len(encounters))
+	for _, encounter := range encounters {
+		// Filter by the steamID (64) and the session
+		filter := bson.D{{Key: "SteamID", Value: encounter.SteamID}, {Key: "SessionID", Value: encounter.SessionID}}
+
+		// The information to be updated
+		update := bson.D{
+			{Key: "$min", Value: bson.D{
+				{Key: "FirstSeen", Value: encounter.FirstSeen},
+			}},
+			{Key: "$set", Value: bson.D{
+				{Key: "Server", Value: encounter.Server},
+				{Key: "Hostname", Value: encounter.Hostname},
+				{Key: "LastSeen", Value: encounter.LastSeen},
+			}},
+		}
+
+		// Upsert the document if it doesn't exist
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
 	}
 
-	// If the URI is empty, use the default
-	if mongoDBName == "" {
-		mongoDBName = "TF2"
+	return bulkWrite(collection, models)
+}
+
+// ensureEncounterIndexes creates the index for looking up the encounters of a player
+func ensureEncounterIndexes(collection *mongo.Collection) {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "SteamID", Value: 1}, {Key: "SessionID", Value: 1}}, Options: options.Index().SetUnique(true)},
 	}
 
-	// Get a handle for your collection
-	collection := client.Database(mongoDBName).Collection("Chats")
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
 
-	// The information to be updated
-	insert := bson.D{
-		{Key: "SteamID", Value: chat.SteamID},
-		{Key: "Name", Value: chat.Name},
-		{Key: "Message", Value: chat.Message},
-		{Key: "UpdatedAt", Value: chat.UpdatedAt},
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Error creating encounter indexes in the DB: %v", err)
+	}
+}
+
+// insertOnce inserts the document with the ID unless it exists already, so retrying a partly written batch adds nothing twice
+func insertOnce(id primitive.ObjectID, document bson.D) mongo.WriteModel {
+	if id.IsZero() {
+		return mongo.NewInsertOneModel().SetDocument(document)
 	}
 
-	// Update the document
-	result, err := collection.InsertOne(context.TODO(), insert)
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$setOnInsert", Value: document}}
 
-	if err != nil {
-		log.Printf("Error adding chat to the DB: %v", err)
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+}
+
+// bulkWrite sends all models to the collection in one round trip, the order of the models does not matter
+func bulkWrite(collection *mongo.Collection, models []mongo.WriteModel) error {
+	if len(models) == 0 {
+		return nil
 	}
 
-	// log.Printf("Number of documents upserted: %v\n", result)
-	return result
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	_, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
 }
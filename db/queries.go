@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// queryTimeout is how long a single query to MongoDB may take
+const queryTimeout = 10 * time.Second
+
+// FindChats returns the latest chat messages of the player, newest first
+func (s *mongoStore) FindChats(steamID int64, limit int64) ([]Chat, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Chats")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "SteamID", Value: steamID}}
+	opts := options.Find().SetSort(bson.D{{Key: "UpdatedAt", Value: -1}}).SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var chats []Chat
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// FindFrags returns the latest frags the player was killer or victim of, newest first
+func (s *mongoStore) FindFrags(steamID int64, limit int64) ([]Frag, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Frags")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "KillerSteamID", Value: steamID}},
+		bson.D{{Key: "VictimSteamID", Value: steamID}},
+	}}}
+	opts := options.Find().SetSort(bson.D{{Key: "UpdatedAt", Value: -1}}).SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var frags []Frag
+	if err := cursor.All(ctx, &frags); err != nil {
+		return nil, err
+	}
+
+	return frags, nil
+}
+
+// FindMarks returns the marks of all players
+func (s *mongoStore) FindMarks() ([]Mark, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Marks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	var marks []Mark
+	if err := cursor.All(ctx, &marks); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+// FindNames returns the names the player used, most recently used first
+func (s *mongoStore) FindNames(steamID int64, limit int64) ([]Name, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Names")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "SteamID", Value: steamID}}
+	opts := options.Find().SetSort(bson.D{{Key: "LastSeen", Value: -1}}).SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []Name
+	if err := cursor.All(ctx, &names); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// FindSteamIDsByName returns the steamID64s of all players that ever used the name, ignoring case
+func (s *mongoStore) FindSteamIDsByName(name string) ([]int64, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Names")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "Name", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}}
+
+	values, err := collection.Distinct(ctx, "SteamID", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	steamIDs := make([]int64, 0, len(values))
+	for _, value := range values {
+		if steamID, ok := value.(int64); ok {
+			steamIDs = append(steamIDs, steamID)
+		}
+	}
+
+	return steamIDs, nil
+}
+
+// FindEncounters sums up the encounters with the given players, the session excludeSessionID is left out
+func (s *mongoStore) FindEncounters(steamIDs []int64, excludeSessionID string) ([]EncounterSummary, error) {
+
+	// Get a handle for your collection
+	collection := s.client.Database(s.name).Collection("Encounters")
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "SteamID", Value: bson.D{{Key: "$in", Value: steamIDs}}},
+			{Key: "SessionID", Value: bson.D{{Key: "$ne", Value: excludeSessionID}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$SteamID"},
+			{Key: "Sessions", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "Seconds", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$subtract", Value: bson.A{"$LastSeen", "$FirstSeen"}}}}}},
+			{Key: "FirstSeen", Value: bson.D{{Key: "$min", Value: "$FirstSeen"}}},
+			{Key: "LastSeen", Value: bson.D{{Key: "$max", Value: "$LastSeen"}}},
+			{Key: "Servers", Value: bson.D{{Key: "$addToSet", Value: "$Server"}}},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []EncounterSummary
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,124 @@
+package db
+
+// AddPlayer queues a player to be added to the database or to update their name
+func AddPlayer(player Player) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.addPlayer(player)
+	return nil
+}
+
+// AddChat queues a chat message to be added to the database
+func AddChat(chat Chat) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.addChat(chat)
+	return nil
+}
+
+// AddFrag queues a frag to be added to the database
+func AddFrag(frag Frag) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.addFrag(frag)
+	return nil
+}
+
+// UpsertSession queues a server session to be added to the database or updated if it already exists
+func UpsertSession(session Session) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.upsertSession(session)
+	return nil
+}
+
+// SetMark stores the mark of a player, a mark without attributes and notes is removed
+func SetMark(mark Mark) error {
+	if store == nil {
+		return ErrDisabled
+	}
+
+	return store.SetMark(mark)
+}
+
+// AddName queues the record that the player used the name, the first sighting of a name is kept
+func AddName(name Name) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.addName(name)
+	return nil
+}
+
+// AddEncounter queues the record that we shared the server session with the player, the first sighting is kept
+func AddEncounter(encounter Encounter) error {
+	if queue == nil {
+		return ErrDisabled
+	}
+
+	queue.addEncounter(encounter)
+	return nil
+}
+
+// FindChats returns the latest chat messages of the player, newest first
+func FindChats(steamID int64, limit int64) ([]Chat, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindChats(steamID, limit)
+}
+
+// FindFrags returns the latest frags the player was killer or victim of, newest first
+func FindFrags(steamID int64, limit int64) ([]Frag, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindFrags(steamID, limit)
+}
+
+// FindMarks returns the marks of all players
+func FindMarks() ([]Mark, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindMarks()
+}
+
+// FindNames returns the names the player used, most recently used first
+func FindNames(steamID int64, limit int64) ([]Name, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindNames(steamID, limit)
+}
+
+// FindSteamIDsByName returns the steamID64s of all players that ever used the name, ignoring case
+func FindSteamIDsByName(name string) ([]int64, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindSteamIDsByName(name)
+}
+
+// FindEncounters sums up the encounters with the given players, the session excludeSessionID is left out
+func FindEncounters(steamIDs []int64, excludeSessionID string) ([]EncounterSummary, error) {
+	if store == nil {
+		return nil, ErrDisabled
+	}
+
+	return store.FindEncounters(steamIDs, excludeSessionID)
+}
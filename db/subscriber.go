@@ -0,0 +1,95 @@
+package db
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/events"
+)
+
+// Subscribe registers the database writer on the given bus
+func Subscribe(bus *events.Bus) {
+	bus.Subscribe(onEvent, events.TypePlayerSeen, events.TypeChatMessage, events.TypeFrag, events.TypeServerInfo)
+}
+
+// onEvent stores players, chats, frags and server sessions as they come in
+func onEvent(e events.Event) {
+	switch e := e.(type) {
+	case events.PlayerSeen:
+		// Create a player document for inserting into MongoDB
+		AddPlayer(Player{
+			SteamID:       e.Player.SteamID,
+			Name:          e.Player.Name,
+			LastSessionID: e.SessionID,
+			UpdatedAt:     time.Now().UnixNano(),
+		})
+
+		// Keep every name the player used, name churn is a cheater signal
+		AddName(Name{
+			SteamID:   e.Player.SteamID,
+			Name:      e.Player.Name,
+			FirstSeen: time.Now().Unix(),
+			LastSeen:  time.Now().Unix(),
+			Server:    e.Server,
+			Hostname:  e.Hostname,
+		})
+
+		// Sightings outside of a known server session can't be counted as encounter
+		if e.SessionID != "" {
+			AddEncounter(Encounter{
+				SteamID:   e.Player.SteamID,
+				SessionID: e.SessionID,
+				Server:    e.Server,
+				Hostname:  e.Hostname,
+				FirstSeen: time.Now().Unix(),
+				LastSeen:  time.Now().Unix(),
+			})
+		}
+
+	case events.ChatMessage:
+		// Chats of unknown players can't be attributed, skip them
+		if e.SteamID == 0 {
+			return
+		}
+
+		// Create a chat document for inserting into MongoDB
+		AddChat(Chat{
+			SteamID:   e.SteamID,
+			Name:      e.Chat.PlayerName,
+			Message:   e.Chat.Message,
+			SessionID: e.SessionID,
+			UpdatedAt: time.Now().UnixNano(),
+		})
+
+	case events.Frag:
+		// Unresolved players are stored with SteamID 0, the frag still counts for the weapon
+		killerSteamID, _ := strconv.ParseInt(e.Info.KillerSteamID, 10, 64)
+		victimSteamID, _ := strconv.ParseInt(e.Info.VictimSteamID, 10, 64)
+
+		// Create a frag document for inserting into MongoDB
+		AddFrag(Frag{
+			KillerSteamID: killerSteamID,
+			VictimSteamID: victimSteamID,
+			KillerName:    e.Info.KillerName,
+			VictimName:    e.Info.VictimName,
+			Weapon:        e.Info.Weapon,
+			Crit:          e.Info.Crit,
+			Map:           e.Map,
+			SessionID:     e.SessionID,
+			UpdatedAt:     time.Now().UnixNano(),
+		})
+
+	case events.ServerInfo:
+		// Create a session document for upserting into MongoDB
+		UpsertSession(Session{
+			SessionID: e.Session.ID,
+			Address:   e.Session.Address,
+			Hostname:  e.Session.Hostname,
+			Map:       e.Session.Map,
+			Tags:      e.Session.Tags,
+			SteamID:   e.Session.SteamID,
+			StartedAt: e.Session.StartedAt,
+			EndedAt:   e.Session.EndedAt,
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Settings of the writer
+const (
+	// writeInterval is how often the queued documents are written
+	writeInterval = 5 * time.Second
+	// batchSize of queued chats and frags wakes the writer before the interval is over
+	batchSize = 500
+	// maxQueued chats and frags are kept while the database is unreachable, the oldest are dropped beyond that
+	maxQueued = 10000
+	// rewriteAfter is how long an unchanged player, name or encounter is not written again, status repeats every few seconds
+	rewriteAfter = time.Minute
+	// maxAttempts is how often a batch is tried when the database is temporarily unreachable
+	maxAttempts = 3
+	// retryDelay is the wait before the first retry, it doubles with every attempt
+	retryDelay = time.Second
+	// closeTimeout is how long closing waits for the last write before it is aborted
+	closeTimeout = 15 * time.Second
+)
+
+// queue is the writer of the opened store, nil while the database is disabled
+var queue *writer
+
+// writer queues the documents and writes them in batches in the background, so a slow database never stalls the console.
+// Players, sessions, names and encounters are coalesced by their key, only the latest version is written.
+type writer struct {
+	store Store
+
+	mu         sync.Mutex
+	players    map[int64]Player
+	sessions   map[string]Session
+	names      map[string]Name
+	encounters map[string]Encounter
+	chats      []Chat
+	frags      []Frag
+	// dropped counts the chats and frags that did not fit in the queue since the last write
+	dropped int
+	// written remembers what was last written per key to skip unchanged documents
+	written map[string]written
+
+	wake chan struct{}
+	stop chan struct{}
+	// abort makes the last write give up, closing took too long
+	abort chan struct{}
+	done  chan struct{}
+}
+
+// written is the content of a document without its timestamps and when it was written
+type written struct {
+	content string
+	at      time.Time
+}
+
+// newWriter starts the writer of the store
+func newWriter(store Store) *writer {
+	w := &writer{
+		store:      store,
+		players:    make(map[int64]Player),
+		sessions:   make(map[string]Session),
+		names:      make(map[string]Name),
+		encounters: make(map[string]Encounter),
+		written:    make(map[string]written),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		abort:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go w.loop()
+	return w
+}
+
+// addPlayer queues the player, a queued version of the player is replaced
+func (w *writer) addPlayer(player Player) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.players[player.SteamID] = player
+}
+
+// upsertSession queues the session, a queued version of the session is replaced
+func (w *writer) upsertSession(session Session) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sessions[session.SessionID] = session
+}
+
+// addName queues the name, the first sighting of a queued version is kept
+func (w *writer) addName(name Name) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := documentKey(name.SteamID, name.Name)
+	if queued, ok := w.names[key]; ok && queued.FirstSeen < name.FirstSeen {
+		name.FirstSeen = queued.FirstSeen
+	}
+
+	w.names[key] = name
+}
+
+// addEncounter queues the encounter, the first sighting of a queued version is kept
+func (w *writer) addEncounter(encounter Encounter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := documentKey(encounter.SteamID, encounter.SessionID)
+	if queued, ok := w.encounters[key]; ok && queued.FirstSeen < encounter.FirstSeen {
+		encounter.FirstSeen = queued.FirstSeen
+	}
+
+	w.encounters[key] = encounter
+}
+
+// addChat queues the chat message
+func (w *writer) addChat(chat Chat) {
+	chat.ID = primitive.NewObjectID()
+
+	w.mu.Lock()
+	if len(w.chats) >= maxQueued {
+		w.chats = w.chats[1:]
+		w.dropped++
+	}
+	w.chats = append(w.chats, chat)
+	full := len(w.chats) >= batchSize
+	w.mu.Unlock()
+
+	if full {
+		w.wakeUp()
+	}
+}
+
+// addFrag queues the frag
+func (w *writer) addFrag(frag Frag) {
+	frag.ID = primitive.NewObjectID()
+
+	w.mu.Lock()
+	if len(w.frags) >= maxQueued {
+		w.frags = w.frags[1:]
+		w.dropped++
+	}
+	w.frags = append(w.frags, frag)
+	full := len(w.frags) >= batchSize
+	w.mu.Unlock()
+
+	if full {
+		w.wakeUp()
+	}
+}
+
+// wakeUp makes the writer write right away instead of waiting for the interval
+func (w *writer) wakeUp() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close writes everything still queued and stops the writer. After closeTimeout the remaining batches are dropped,
+// close still waits for the write in progress so the store is not closed underneath it.
+func (w *writer) close() {
+	close(w.stop)
+
+	select {
+	case <-w.done:
+	case <-time.After(closeTimeout):
+		log.Printf("Gave up writing the queued documents to the DB after %s", closeTimeout)
+		close(w.abort)
+		<-w.done
+	}
+}
+
+// loop writes the queue every writeInterval until the writer is closed
+func (w *writer) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush(false)
+		case <-w.wake:
+			w.flush(false)
+		case <-w.stop:
+			w.flush(true)
+			return
+		}
+	}
+}
+
+// flush writes the queued documents, unchanged documents that were written recently wait unless final is set
+func (w *writer) flush(final bool) {
+	now := time.Now()
+
+	w.mu.Lock()
+	// Forget old writes, they are due again anyway
+	for key, last := range w.written {
+		if now.Sub(last.at) >= rewriteAfter {
+			delete(w.written, key)
+		}
+	}
+
+	var players []Player
+	for steamID, player := range w.players {
+		if w.due(playerKey(player), player.Name+"\x00"+player.LastSessionID, now, final) {
+			players = append(players, player)
+			delete(w.players, steamID)
+		}
+	}
+
+	var names []Name
+	for key, name := range w.names {
+		if w.due("name:"+key, name.Server+"\x00"+name.Hostname, now, final) {
+			names = append(names, name)
+			delete(w.names, key)
+		}
+	}
+
+	var encounters []Encounter
+	for key, encounter := range w.encounters {
+		if w.due("encounter:"+key, encounter.Server+"\x00"+encounter.Hostname, now, final) {
+			encounters = append(encounters, encounter)
+			delete(w.encounters, key)
+		}
+	}
+
+	// Sessions only change on a new server or map, they are always written
+	sessions := make([]Session, 0, len(w.sessions))
+	for id, session := range w.sessions {
+		sessions = append(sessions, session)
+		delete(w.sessions, id)
+	}
+
+	chats, frags := w.chats, w.frags
+	w.chats, w.frags = nil, nil
+
+	if w.dropped > 0 {
+		log.Printf("The DB write queue was full, dropped the %d oldest chats and frags", w.dropped)
+		w.dropped = 0
+	}
+	w.mu.Unlock()
+
+	if len(players) > 0 && w.write("players", func() error { return w.store.AddPlayers(players) }, final) {
+		w.requeuePlayers(players)
+	}
+	if len(names) > 0 && w.write("names", func() error { return w.store.AddNames(names) }, final) {
+		w.requeueNames(names)
+	}
+	if len(encounters) > 0 && w.write("encounters", func() error { return w.store.AddEncounters(encounters) }, final) {
+		w.requeueEncounters(encounters)
+	}
+	if len(sessions) > 0 && w.write("sessions", func() error { return w.store.UpsertSessions(sessions) }, final) {
+		w.requeueSessions(sessions)
+	}
+	if len(chats) > 0 && w.write("chats", func() error { return w.store.AddChats(chats) }, final) {
+		w.requeueChats(chats)
+	}
+	if len(frags) > 0 && w.write("frags", func() error { return w.store.AddFrags(frags) }, final) {
+		w.requeueFrags(frags)
+	}
+}
+
+// due reports whether the document with the key and content should be written now and remembers it as written if so.
+// The caller holds the lock.
+func (w *writer) due(key string, content string, now time.Time, final bool) bool {
+	last, ok := w.written[key]
+	if ok && last.content == content && now.Sub(last.at) < rewriteAfter && !final {
+		return false
+	}
+
+	w.written[key] = written{content: content, at: now}
+	return true
+}
+
+// write runs the batch write and retries it while the database is temporarily unreachable.
+// It returns true if the batch failed for a temporary reason and should be queued again.
+func (w *writer) write(what string, batch func() error, final bool) bool {
+	delay := retryDelay
+
+	for attempt := 1; ; attempt++ {
+		if w.aborted() {
+			log.Printf("Dropping the %s, writing to the DB was aborted", what)
+			return false
+		}
+
+		err := batch()
+		if err == nil {
+			return false
+		}
+
+		if !isTransient(err) {
+			log.Printf("Error writing %s to the DB, dropping them: %v", what, err)
+			return false
+		}
+
+		if attempt == maxAttempts {
+			// The last write has nobody to pick up the queue
+			if final {
+				log.Printf("Error writing %s to the DB, dropping them: %v", what, err)
+				return false
+			}
+
+			log.Printf("Error writing %s to the DB, trying again later: %v", what, err)
+			return true
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-w.abort:
+		}
+		delay *= 2
+	}
+}
+
+// aborted reports whether closing gave up on the writer
+func (w *writer) aborted() bool {
+	select {
+	case <-w.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// requeuePlayers queues the players again unless a newer version is queued already
+func (w *writer) requeuePlayers(players []Player) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, player := range players {
+		delete(w.written, playerKey(player))
+		if _, ok := w.players[player.SteamID]; !ok {
+			w.players[player.SteamID] = player
+		}
+	}
+}
+
+// requeueNames queues the names again, a queued version gets the first sighting of both
+func (w *writer) requeueNames(names []Name) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, name := range names {
+		key := documentKey(name.SteamID, name.Name)
+		delete(w.written, "name:"+key)
+
+		if queued, ok := w.names[key]; ok {
+			if name.FirstSeen < queued.FirstSeen {
+				queued.FirstSeen = name.FirstSeen
+				w.names[key] = queued
+			}
+			continue
+		}
+		w.names[key] = name
+	}
+}
+
+// requeueEncounters queues the encounters again, a queued version gets the first sighting of both
+func (w *writer) requeueEncounters(encounters []Encounter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, encounter := range encounters {
+		key := documentKey(encounter.SteamID, encounter.SessionID)
+		delete(w.written, "encounter:"+key)
+
+		if queued, ok := w.encounters[key]; ok {
+			if encounter.FirstSeen < queued.FirstSeen {
+				queued.FirstSeen = encounter.FirstSeen
+				w.encounters[key] = queued
+			}
+			continue
+		}
+		w.encounters[key] = encounter
+	}
+}
+
+// requeueSessions queues the sessions again unless a newer version is queued already
+func (w *writer) requeueSessions(sessions []Session) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, session := range sessions {
+		if _, ok := w.sessions[session.SessionID]; !ok {
+			w.sessions[session.SessionID] = session
+		}
+	}
+}
+
+// requeueChats puts the chats back in front of the queue
+func (w *writer) requeueChats(chats []Chat) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.chats = append(chats, w.chats...)
+	if excess := len(w.chats) - maxQueued; excess > 0 {
+		w.chats = w.chats[excess:]
+		w.dropped += excess
+	}
+}
+
+// requeueFrags puts the frags back in front of the queue
+func (w *writer) requeueFrags(frags []Frag) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.frags = append(frags, w.frags...)
+	if excess := len(w.frags) - maxQueued; excess > 0 {
+		w.frags = w.frags[excess:]
+		w.dropped += excess
+	}
+}
+
+// playerKey is the key of the player in the written documents
+func playerKey(player Player) string {
+	return "player:" + strconv.FormatInt(player.SteamID, 10)
+}
+
+// isTransient reports whether the error is worth retrying, the database was unreachable or too slow
+func isTransient(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) || mongo.IsNetworkError(err)
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// failingStore fails the first `failures` chat writes with a timeout and records the IDs of every attempt
+type failingStore struct {
+	Store
+
+	mu       sync.Mutex
+	failures int
+	attempts [][]string
+}
+
+func (s *failingStore) AddChats(chats []Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, len(chats))
+	for i, chat := range chats {
+		ids[i] = chat.ID.Hex()
+	}
+	s.attempts = append(s.attempts, ids)
+
+	if len(s.attempts) <= s.failures {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestRetryKeepsIDs(t *testing.T) {
+	s := &failingStore{failures: 1}
+	w := newWriter(s)
+
+	w.addChat(Chat{SteamID: 1, Message: "gg"})
+	w.addChat(Chat{SteamID: 1, Message: "gg"})
+	w.close()
+
+	if len(s.attempts) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(s.attempts))
+	}
+
+	first, retry := s.attempts[0], s.attempts[1]
+	if len(first) != 2 || first[0] == first[1] {
+		t.Fatalf("IDs %v, want one per chat, also for equal chats", first)
+	}
+	for i := range first {
+		if first[i] != retry[i] {
+			t.Fatalf("the retry has IDs %v, want %v", retry, first)
+		}
+	}
+}
+
+func TestAbortStopsWriting(t *testing.T) {
+	s := &failingStore{failures: maxAttempts}
+	w := newWriter(s)
+	close(w.abort)
+
+	retry := w.write("chats", func() error { return s.AddChats([]Chat{{SteamID: 1}}) }, true)
+	if retry || len(s.attempts) != 0 {
+		t.Fatalf("write() = %v after %d attempts, want it to give up without writing", retry, len(s.attempts))
+	}
+
+	w.close()
+}
@@ -0,0 +1,233 @@
+// Package detection evaluates configurable rules against players and chat lines to spot bots and cheaters.
+package detection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Engine evaluates the rules, each rule matches a player at most once every refireAfter
+type Engine struct {
+	rules []Rule
+
+	mu    sync.Mutex
+	fired map[string]time.Time
+}
+
+// Match is a rule that matched, Rule carries the action to run
+type Match struct {
+	Rule Rule
+	Info *utils.DetectionInfo
+}
+
+// NewEngine compiles the rules
+func NewEngine(rules []Rule) (*Engine, error) {
+	compiled := make([]Rule, len(rules))
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		compiled[i] = rule
+	}
+
+	return &Engine{rules: compiled, fired: make(map[string]time.Time)}, nil
+}
+
+// CheckPlayer evaluates all player rules, players are all players currently in the game
+func (e *Engine) CheckPlayer(player *utils.PlayerInfo, players []*utils.PlayerInfo) []Match {
+	// Never flag ourselves
+	if player.IsMe {
+		return nil
+	}
+
+	var matches []Match
+	for _, rule := range e.rules {
+		var evidence string
+
+		switch rule.Kind {
+		case KindName:
+			if found := rule.pattern.FindString(player.Name); found != "" {
+				evidence = fmt.Sprintf("name contains '%s'", found)
+			}
+		case KindNameStealing:
+			evidence = nameStealing(player, players)
+		case KindPing:
+			if player.Ping > rule.MaxPing {
+				evidence = fmt.Sprintf("ping %d is above %d", player.Ping, rule.MaxPing)
+			}
+		case KindAccount:
+			if accountID := utils.Steam64ToSteam3ID(player.SteamID); accountID >= rule.MinAccountID {
+				evidence = fmt.Sprintf("account [U:1:%d] was created recently", accountID)
+			}
+		}
+
+		if evidence != "" && e.fire(rule, player.SteamID, player.Name) {
+			matches = append(matches, e.match(rule, player.SteamID, player.Name, evidence))
+		}
+	}
+
+	return matches
+}
+
+// CheckChat evaluates all chat rules, steamID is 0 if the sender is unknown and isMe is set for our own lines
+func (e *Engine) CheckChat(chat *utils.ChatInfo, steamID int64, isMe bool) []Match {
+	// Never flag ourselves
+	if isMe {
+		return nil
+	}
+
+	var matches []Match
+	for _, rule := range e.rules {
+		if rule.Kind != KindChat {
+			continue
+		}
+
+		if rule.pattern.MatchString(chat.Message) && e.fire(rule, steamID, chat.PlayerName) {
+			matches = append(matches, e.match(rule, steamID, chat.PlayerName, fmt.Sprintf("said '%s'", chat.Message)))
+		}
+	}
+
+	return matches
+}
+
+// fire reports whether the rule may match the player again and remembers that it did.
+// Unknown players are told apart by name, one of them must not silence the rule for all others.
+func (e *Engine) fire(rule Rule, steamID int64, name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+
+	key := rule.Name + "\x00" + strconv.FormatInt(steamID, 10)
+	if steamID == 0 {
+		key = rule.Name + "\x00name:" + name
+	}
+
+	if last, ok := e.fired[key]; ok && now.Sub(last) < refireAfter {
+		return false
+	}
+
+	if len(e.fired) >= maxFired {
+		e.expireFired(now)
+	}
+
+	e.fired[key] = now
+	return true
+}
+
+// expireFired forgets the matches that no longer keep their rule quiet, the caller holds the lock
+func (e *Engine) expireFired(now time.Time) {
+	for key, last := range e.fired {
+		if now.Sub(last) >= refireAfter {
+			delete(e.fired, key)
+		}
+	}
+}
+
+// match builds the match of the rule
+func (e *Engine) match(rule Rule, steamID int64, name string, evidence string) Match {
+	log.Printf("Detection '%s' matched '%s' (%d): %s", rule.Name, name, steamID, evidence)
+
+	return Match{
+		Rule: rule,
+		Info: &utils.DetectionInfo{
+			Rule:       rule.Name,
+			Kind:       rule.Kind,
+			SteamID:    steamID,
+			Name:       name,
+			Evidence:   evidence,
+			DetectedAt: time.Now().Unix(),
+		},
+	}
+}
+
+// nameStealing returns why the name of the player looks stolen, empty if it does not.
+// Of two players looking alike, only the newer account (the higher account ID) is flagged as impersonator.
+func nameStealing(player *utils.PlayerInfo, players []*utils.PlayerInfo) string {
+	canonical := utils.CanonicalName(player.Name)
+	accountID := utils.Steam64ToSteam3ID(player.SteamID)
+
+	for _, other := range players {
+		if other.SteamID == player.SteamID || utils.CanonicalName(other.Name) != canonical {
+			continue
+		}
+
+		if utils.Steam64ToSteam3ID(other.SteamID) > accountID {
+			continue
+		}
+
+		evidence := fmt.Sprintf("impersonates '%s' (%d) with a newer account", other.Name, other.SteamID)
+		if lookalikes := disguise(player.Name); other.Name != player.Name && len(lookalikes) > 0 {
+			evidence += " using the characters " + strings.Join(lookalikes, " ")
+		}
+
+		return evidence
+	}
+
+	// Lookalike characters alone are fine, e.g. in cyrillic names
+	if hidden := invisibleCharacters(player.Name); len(hidden) > 0 {
+		return fmt.Sprintf("name hides the characters %s", strings.Join(hidden, " "))
+	}
+
+	return ""
+}
+
+// disguise lists the characters a name imitates another one with, the non-ASCII ones of an otherwise latin name
+func disguise(name string) []string {
+	canonical := utils.CanonicalName(name)
+	for _, r := range canonical {
+		if r > unicode.MaxASCII {
+			// Genuinely non-latin names are not disguised
+			return invisibleCharacters(name)
+		}
+	}
+
+	var found []string
+	for _, r := range name {
+		if r > unicode.MaxASCII {
+			found = append(found, fmt.Sprintf("%U", r))
+		}
+	}
+
+	return found
+}
+
+// invisibleCharacters lists the invisible characters of a name
+func invisibleCharacters(name string) []string {
+	var found []string
+	for _, r := range name {
+		if utils.IsInvisible(r) {
+			found = append(found, fmt.Sprintf("%U", r))
+		}
+	}
+
+	return found
+}
+
+// Action returns the RCON command of the match with all placeholders replaced, empty if the rule has none
+func (m Match) Action(userID int) string {
+	if m.Rule.Action == "" {
+		return ""
+	}
+
+	// Player names must not break out of the command
+	replacer := strings.NewReplacer(
+		"{name}", sanitize(m.Info.Name),
+		"{steamid}", strconv.FormatInt(m.Info.SteamID, 10),
+		"{userid}", strconv.Itoa(userID),
+		"{rule}", sanitize(m.Info.Rule),
+	)
+
+	return replacer.Replace(m.Rule.Action)
+}
+
+// sanitize removes everything from the value that would end the RCON command or its quoted argument
+func sanitize(value string) string {
+	return strings.NewReplacer(`"`, "'", ";", ",", "\n", " ", "\r", " ").Replace(value)
+}
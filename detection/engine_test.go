@@ -0,0 +1,154 @@
+package detection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// account returns a player with the account ID, lower account IDs are older accounts
+func account(accountID int64, name string) *utils.PlayerInfo {
+	return &utils.PlayerInfo{SteamID: utils.Steam3IDToSteam64(accountID), Name: name, Ping: 50, LastSeen: time.Now().Unix()}
+}
+
+// newEngine compiles the rules or fails the test
+func newEngine(t *testing.T, rules ...Rule) *Engine {
+	t.Helper()
+
+	engine, err := NewEngine(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return engine
+}
+
+func TestCheckPlayer(t *testing.T) {
+	original := account(1000, "atomy")
+	me := account(1001, "me")
+	me.IsMe = true
+
+	cases := []struct {
+		name   string
+		rule   Rule
+		player *utils.PlayerInfo
+		others []*utils.PlayerInfo
+		match  bool
+	}{
+		{"bot name", Rule{Name: "bot-name", Kind: KindName, Pattern: `(?i)cathook`}, account(2000, "CATHOOK.club"), nil, true},
+		{"ordinary name", Rule{Name: "bot-name", Kind: KindName, Pattern: `(?i)cathook`}, account(2000, "atomy"), nil, false},
+		{"high ping", Rule{Name: "ping", Kind: KindPing, MaxPing: 500}, &utils.PlayerInfo{SteamID: utils.Steam3IDToSteam64(2000), Name: "lag", Ping: 700}, nil, true},
+		{"normal ping", Rule{Name: "ping", Kind: KindPing, MaxPing: 500}, account(2000, "lag"), nil, false},
+		{"new account", Rule{Name: "new", Kind: KindAccount, MinAccountID: 1500000000}, account(1600000000, "fresh"), nil, true},
+		{"old account", Rule{Name: "new", Kind: KindAccount, MinAccountID: 1500000000}, account(1000, "veteran"), nil, false},
+		{"copied name", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "atomy"), []*utils.PlayerInfo{original}, true},
+		{"homoglyph copy", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "\u0430t\u043emy"), []*utils.PlayerInfo{original}, true},
+		{"fullwidth copy", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "\uff41\uff54\uff4f\uff4d\uff59"), []*utils.PlayerInfo{original}, true},
+		{"zero-width copy", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "ato\u200bmy"), []*utils.PlayerInfo{original}, true},
+		{"the original", Rule{Name: "stealing", Kind: KindNameStealing}, original, []*utils.PlayerInfo{account(2000, "atomy")}, false},
+		{"hidden characters", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "sneaky\u200b"), nil, true},
+		{"cyrillic name", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "Дмитрий"), nil, false},
+		{"ourselves", Rule{Name: "bot-name", Kind: KindName, Pattern: `me`}, me, nil, false},
+		{"our name copied", Rule{Name: "stealing", Kind: KindNameStealing}, account(2000, "me"), []*utils.PlayerInfo{me}, true},
+	}
+
+	for _, c := range cases {
+		players := append([]*utils.PlayerInfo{c.player}, c.others...)
+
+		matches := newEngine(t, c.rule).CheckPlayer(c.player, players)
+		if (len(matches) > 0) != c.match {
+			t.Errorf("%s: CheckPlayer(%q) = %d matches, want a match: %v", c.name, c.player.Name, len(matches), c.match)
+			continue
+		}
+
+		if c.match && (matches[0].Info.SteamID != c.player.SteamID || matches[0].Info.Evidence == "") {
+			t.Errorf("%s: the match %+v is not about %d or has no evidence", c.name, matches[0].Info, c.player.SteamID)
+		}
+	}
+}
+
+func TestCheckPlayerRefire(t *testing.T) {
+	engine := newEngine(t, Rule{Name: "bot-name", Kind: KindName, Pattern: `cathook`})
+	bot := account(2000, "cathook")
+
+	if len(engine.CheckPlayer(bot, nil)) != 1 {
+		t.Fatal("the first sighting did not match")
+	}
+	if len(engine.CheckPlayer(bot, nil)) != 0 {
+		t.Fatal("the rule matched the same player again right away")
+	}
+	if len(engine.CheckPlayer(account(2001, "cathook"), nil)) != 1 {
+		t.Fatal("the rule stayed quiet for another player")
+	}
+}
+
+func TestCheckChat(t *testing.T) {
+	spam := Rule{Name: "spam", Kind: KindChat, Pattern: `discord\.gg/`}
+
+	chat := func(name string) *utils.ChatInfo {
+		return &utils.ChatInfo{PlayerName: name, Message: "join discord.gg/bots"}
+	}
+
+	cases := []struct {
+		name    string
+		senders []int64
+		names   []string
+		isMe    bool
+		want    int
+	}{
+		{"spammer", []int64{1}, []string{"bot"}, false, 1},
+		{"ourselves", []int64{1}, []string{"me"}, true, 0},
+		{"same spammer twice", []int64{1, 1}, []string{"bot", "bot"}, false, 1},
+		{"two unknown spammers", []int64{0, 0}, []string{"bot", "other bot"}, false, 2},
+		{"same unknown spammer twice", []int64{0, 0}, []string{"bot", "bot"}, false, 1},
+	}
+
+	for _, c := range cases {
+		engine := newEngine(t, spam)
+
+		matches := 0
+		for i, steamID := range c.senders {
+			matches += len(engine.CheckChat(chat(c.names[i]), steamID, c.isMe))
+		}
+
+		if matches != c.want {
+			t.Errorf("%s: %d matches, want %d", c.name, matches, c.want)
+		}
+	}
+
+	if matches := newEngine(t, spam).CheckChat(&utils.ChatInfo{PlayerName: "a", Message: "gg"}, 1, false); len(matches) != 0 {
+		t.Errorf("CheckChat(gg) = %d matches, want none", len(matches))
+	}
+}
+
+func TestFiredIsPruned(t *testing.T) {
+	engine := newEngine(t, Rule{Name: "bot-name", Kind: KindName, Pattern: `bot`})
+
+	// Matches older than refireAfter no longer keep the rule quiet, they are cleaned up
+	for i := 0; i < maxFired; i++ {
+		engine.fired["old\x00"+string(rune('a'+i%26))+string(rune('a'+i/26))] = time.Now().Add(-2 * refireAfter)
+	}
+
+	engine.CheckPlayer(account(2000, "bot"), nil)
+
+	if len(engine.fired) != 1 {
+		t.Fatalf("%d tracked matches, want only the new one", len(engine.fired))
+	}
+}
+
+func TestActionSanitizes(t *testing.T) {
+	match := Match{
+		Rule: Rule{Action: `callvote kick "{userid} cheating"; say "{name} is a {rule}" {steamid}`},
+		Info: &utils.DetectionInfo{Rule: "bot;quit", SteamID: 76561197960265729, Name: "a\"; quit\n"},
+	}
+
+	want := `callvote kick "7 cheating"; say "a', quit  is a bot,quit" 76561197960265729`
+	if got := match.Action(7); got != want {
+		t.Fatalf("Action() = %q, want %q", got, want)
+	}
+
+	if got := (Match{Rule: Rule{}, Info: match.Info}).Action(7); got != "" {
+		t.Fatalf("Action() without action = %q, want it empty", got)
+	}
+}
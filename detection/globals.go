@@ -0,0 +1,33 @@
+package detection
+
+import (
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/logger"
+)
+
+// Create a new instance of the logger.
+var log = logger.Logger
+
+// Kinds of rules, they decide which fields of a rule are used
+const (
+	// KindName matches Pattern against player names
+	KindName = "name"
+	// KindChat matches Pattern against chat messages
+	KindChat = "chat"
+	// KindNameStealing matches players sharing their name with another player or hiding characters in it
+	KindNameStealing = "name-stealing"
+	// KindPing matches players with a ping above MaxPing
+	KindPing = "ping"
+	// KindAccount matches accounts with an account ID of at least MinAccountID, i.e. recently created ones
+	KindAccount = "account"
+)
+
+// refireAfter is how long a rule stays quiet for a player after it matched
+const refireAfter = 10 * time.Minute
+
+// maxFired is the number of tracked matches before the expired ones are cleaned up
+const maxFired = 256
+
+// defaultPath is the rules file that is read when none is given
+const defaultPath = "rules.json"
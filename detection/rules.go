@@ -0,0 +1,86 @@
+package detection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Rule is a single detection rule, rules files hold a JSON array of them
+type Rule struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Pattern is the regular expression of name and chat rules
+	Pattern string `json:"pattern,omitempty"`
+	// MaxPing is the highest ping ping rules accept
+	MaxPing int `json:"maxPing,omitempty"`
+	// MinAccountID is the first account ID (the number in [U:1:<id>]) account rules flag
+	MinAccountID int64 `json:"minAccountID,omitempty"`
+	// Action is an optional RCON command run when the rule matches, {name}, {steamid}, {userid} and {rule} are replaced
+	Action string `json:"action,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// DefaultRules returns the rules used when there is no rules file
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "bot-name", Kind: KindName, Pattern: `(?i)(cathook|catbot|myg[0o]t|\bbot ?detector\b)`},
+		{Name: "bot-chat-spam", Kind: KindChat, Pattern: `(?i)(cathook|catbot|myg[0o]t|discord\.gg/|t\.me/)`},
+		{Name: "name-stealing", Kind: KindNameStealing},
+		{Name: "excessive-ping", Kind: KindPing, MaxPing: 500},
+	}
+}
+
+// LoadRules reads the rules file at path, the default file is optional and falls back to the default rules
+func LoadRules(path string) ([]Rule, error) {
+	if path == "" {
+		path = defaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == defaultPath {
+		return DefaultRules(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rules file %s: %w", path, err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse rules file %s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// compile validates the rule and compiles its pattern
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return errors.New("rule without name")
+	}
+
+	switch r.Kind {
+	case KindName, KindChat:
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.pattern = pattern
+	case KindPing:
+		if r.MaxPing <= 0 {
+			return fmt.Errorf("rule %s: maxPing must be positive", r.Name)
+		}
+	case KindAccount:
+		if r.MinAccountID <= 0 {
+			return fmt.Errorf("rule %s: minAccountID must be positive", r.Name)
+		}
+	case KindNameStealing:
+	default:
+		return fmt.Errorf("rule %s: unknown kind %q", r.Name, r.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package detection
+
+import (
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/state"
+)
+
+// Subscribe checks every player seen in `status` and every chat line, matches are published as detection events
+func Subscribe(bus *events.Bus, engine *Engine, players *state.PlayerRegistry) {
+	bus.Subscribe(func(e events.Event) {
+		var matches []Match
+
+		// Only the registry knows who we are, the parsed player does not
+		me := players.MySteamID()
+
+		switch e := e.(type) {
+		case events.PlayerSeen:
+			player := *e.Player
+			player.IsMe = me != 0 && player.SteamID == me
+			matches = engine.CheckPlayer(&player, players.Snapshot())
+		case events.ChatMessage:
+			matches = engine.CheckChat(e.Chat, e.SteamID, me != 0 && e.SteamID == me)
+		}
+
+		for _, match := range matches {
+			bus.Publish(events.Detection{Info: match.Info})
+			runAction(match, players)
+		}
+	}, events.TypePlayerSeen, events.TypeChatMessage)
+}
+
+// runAction queues the RCON action of the matched rule, actions need to know who they are about
+func runAction(match Match, players *state.PlayerRegistry) {
+	if match.Rule.Action == "" || match.Info.SteamID == 0 {
+		return
+	}
+
+	player, ok := players.LookupBySteamID(match.Info.SteamID)
+	if !ok {
+		return
+	}
+
+	network.RconQueue(match.Action(player.UserID), network.PriorityLow)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+var (
+	encountersMu sync.Mutex
+	// encountersLoaded holds the session the encounters of a player were loaded in, by steamID64
+	encountersLoaded = make(map[int64]string)
+)
+
+// loadEncounters attaches our earlier encounters with the player, once per server session and without blocking the caller
+func loadEncounters(steamID int64, sessionID string) {
+	encountersMu.Lock()
+	loaded, ok := encountersLoaded[steamID]
+	encountersLoaded[steamID] = sessionID
+	encountersMu.Unlock()
+
+	if ok && loaded == sessionID {
+		return
+	}
+
+	go func() {
+		// The current session is still going on, it is not an earlier encounter
+		summaries, err := db.FindEncounters([]int64{steamID}, sessionID)
+		if err != nil {
+			if !errors.Is(err, db.ErrDisabled) {
+				log.Printf("Unable to load the encounters with %d: %v", steamID, err)
+			}
+			return
+		}
+
+		for _, summary := range summaries {
+			playersInGame.SetEncounters(summary.SteamID, &utils.Encounters{
+				Sessions:  summary.Sessions,
+				Minutes:   summary.Seconds / 60,
+				Servers:   len(summary.Servers),
+				FirstSeen: summary.FirstSeen,
+				LastSeen:  summary.LastSeen,
+			})
+		}
+	}()
+}
+
+// forgetEncounters drops the players that left, their encounters are loaded again when they come back
+func forgetEncounters(steamIDs []int64) {
+	encountersMu.Lock()
+	defer encountersMu.Unlock()
+
+	for _, steamID := range steamIDs {
+		delete(encountersLoaded, steamID)
+	}
+}
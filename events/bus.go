@@ -0,0 +1,56 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/algo7/tf2_rcon_misc/logger"
+)
+
+// Create a new instance of the logger.
+var log = logger.Logger
+
+// Handler is called for every event of the type it subscribed to
+type Handler func(Event)
+
+// Bus is a publish/subscribe bus that delivers events to all subscribers of their type
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Type][]Handler)}
+}
+
+// Subscribe registers the handler for all future events of the given types
+func (b *Bus) Subscribe(handler Handler, types ...Type) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, t := range types {
+		b.handlers[t] = append(b.handlers[t], handler)
+	}
+}
+
+// Publish delivers the event to every subscriber of its type, in order of subscription
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	handlers := b.handlers[e.Type()]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		deliver(handler, e)
+	}
+}
+
+// deliver calls the handler and keeps a panicking subscriber from taking down the publisher
+func deliver(handler Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Subscriber for '%s' panicked: %v", e.Type(), r)
+		}
+	}()
+
+	handler(e)
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Type identifies the kind of event published on the bus
+type Type string
+
+const (
+	// TypePlayerSeen is published for every player line of a `status` response
+	TypePlayerSeen Type = "player-seen"
+	// TypeChatMessage is published for every chat line
+	TypeChatMessage Type = "chat-message"
+	// TypeFrag is published for every kill line
+	TypeFrag Type = "frag"
+	// TypeSuicide is published for every suicide line
+	TypeSuicide Type = "suicide"
+	// TypeConnected is published when a player connects to the server
+	TypeConnected Type = "connected"
+	// TypeLobbyUpdated is published when the game reports a lobby update
+	TypeLobbyUpdated Type = "lobby-updated"
+	// TypeServerInfo is published whenever the server session starts, changes or ends
+	TypeServerInfo Type = "server-info"
+	// TypeRconStatus is published whenever the RCON connection status changes
+	TypeRconStatus Type = "rcon-status"
+	// TypeVoteCooldown is published when the server refuses a vote because we have to wait
+	TypeVoteCooldown Type = "vote-cooldown"
+	// TypeDetection is published when a detection rule matched a player
+	TypeDetection Type = "detection"
+)
+
+// Event is implemented by every message that goes over the bus
+type Event interface {
+	Type() Type
+}
+
+// Events that happen on a server carry the ID of the server session, it is empty if no session is known yet.
+
+// PlayerSeen carries a player parsed from a `status` line and the address and hostname of the server
+type PlayerSeen struct {
+	Player    *utils.PlayerInfo
+	SessionID string
+	Server    string
+	Hostname  string
+}
+
+// ChatMessage carries a parsed chat line, SteamID is 0 if the sender could not be resolved
+type ChatMessage struct {
+	Chat      *utils.ChatInfo
+	SteamID   int64
+	SessionID string
+}
+
+// Frag carries a parsed kill line with the SteamIDs already resolved and the map it happened on
+type Frag struct {
+	Info      *utils.FragInfo
+	Map       string
+	SessionID string
+}
+
+// Suicide carries a parsed suicide line with the SteamID already resolved
+type Suicide struct {
+	Info      *utils.SuicideInfo
+	SessionID string
+}
+
+// Connected carries the raw console line of a player connecting
+type Connected struct {
+	Line string
+}
+
+// LobbyUpdated carries the raw console line of a lobby update
+type LobbyUpdated struct {
+	Line string
+}
+
+// ServerInfo carries the current state of a server session, EndedAt is set once we left the server
+type ServerInfo struct {
+	Session utils.ServerSession
+}
+
+// RconStatus carries the state of the RCON connection
+type RconStatus struct {
+	Info *utils.RconStatusInfo
+}
+
+// VoteCooldown carries the time we have to wait before we can call the next vote
+type VoteCooldown struct {
+	Wait time.Duration
+}
+
+// Detection carries the rule that matched and its evidence
+type Detection struct {
+	Info *utils.DetectionInfo
+}
+
+// Type returns TypePlayerSeen
+func (PlayerSeen) Type() Type { return TypePlayerSeen }
+
+// Type returns TypeChatMessage
+func (ChatMessage) Type() Type { return TypeChatMessage }
+
+// Type returns TypeFrag
+func (Frag) Type() Type { return TypeFrag }
+
+// Type returns TypeSuicide
+func (Suicide) Type() Type { return TypeSuicide }
+
+// Type returns TypeConnected
+func (Connected) Type() Type { return TypeConnected }
+
+// Type returns TypeLobbyUpdated
+func (LobbyUpdated) Type() Type { return TypeLobbyUpdated }
+
+// Type returns TypeServerInfo
+func (ServerInfo) Type() Type { return TypeServerInfo }
+
+// Type returns TypeRconStatus
+func (RconStatus) Type() Type { return TypeRconStatus }
+
+// Type returns TypeVoteCooldown
+func (VoteCooldown) Type() Type { return TypeVoteCooldown }
+
+// Type returns TypeDetection
+func (Detection) Type() Type { return TypeDetection }
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/gorilla/websocket"
+	"github.com/algo7/tf2_rcon_misc/protocol"
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -20,13 +21,16 @@ var (
 	Logger *AppLogger
 )
 
-// LogMessage is a struct for log-messages over websockets, it has its dedicated type
-type LogMessage struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
+// Broadcaster delivers log-messages to the websocket clients, it must not log itself.
+// Log-messages may be dropped for clients that fall behind, a burst of logs must not disconnect them.
+type Broadcaster interface {
+	SendLossy(data []byte)
 }
 
-var wsConnection *websocket.Conn
+var (
+	broadcasterMu sync.RWMutex
+	broadcaster   Broadcaster
+)
 
 // init initialize the logger
 func init() {
@@ -42,7 +46,7 @@ func (l *AppLogger) Log(m string) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	msg := "[" + timestamp + "] " + m
 	l.Printf("%s", msg)
-	sendLogs(wsConnection, msg)
+	sendLogs(msg)
 }
 
 // Printf formats and logs the given message with a timestamp
@@ -50,7 +54,7 @@ func (l *AppLogger) Printf(format string, v ...interface{}) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	formattedMsg := fmt.Sprintf("["+timestamp+"] "+format, v...)
 	l.Logger.Printf(formattedMsg)
-	sendLogs(wsConnection, formattedMsg)
+	sendLogs(formattedMsg)
 }
 
 // Println logs the given message with a timestamp
@@ -60,32 +64,36 @@ func (l *AppLogger) Println(v ...interface{}) {
 	buf.WriteString("[" + timestamp + "] ")
 	buf.WriteString(fmt.Sprintln(v...))
 	l.Logger.Print(buf.String())
-	sendLogs(wsConnection, buf.String())
+	sendLogs(buf.String())
 }
 
-// SetWsConnection set package-global wsConnection var when it comes available
-func (l *AppLogger) SetWsConnection(connection *websocket.Conn) {
-	wsConnection = connection
+// SetBroadcaster sets the package-global broadcaster for log-messages once the websocket is available
+func (l *AppLogger) SetBroadcaster(b Broadcaster) {
+	broadcasterMu.Lock()
+	defer broadcasterMu.Unlock()
+
+	broadcaster = b
 }
 
-// sendLogs send the log message over ws if ws-connection is present
-func sendLogs(ws *websocket.Conn, s string) {
-	if ws != nil {
-		sendLogMessage(ws, s)
+// sendLogs send the log message over ws if a broadcaster is present
+func sendLogs(s string) {
+	broadcasterMu.RLock()
+	b := broadcaster
+	broadcasterMu.RUnlock()
+
+	if b != nil {
+		sendLogMessage(b, s)
 	}
 }
 
-// sendLogMessage sends given log message over provided ws connection
-func sendLogMessage(c *websocket.Conn, s string) {
+// sendLogMessage sends given log message to the provided broadcaster
+func sendLogMessage(b Broadcaster, s string) {
 	if len(s) == 0 {
 		// log.Printf("SendPlayers() Player slice is empty, not sending")
 		return
 	}
 
-	logMessage := LogMessage{
-		Type:    "application-log",
-		Message: s,
-	}
+	logMessage := protocol.New(protocol.TypeApplicationLog, protocol.LogPayload{Message: s})
 
 	// Convert the player data to a JSON string
 	jsonData, err := json.Marshal(logMessage)
@@ -94,10 +102,5 @@ func sendLogMessage(c *websocket.Conn, s string) {
 		return
 	}
 
-	//fmt.Printf("Sending log-message, json-payload is: %s", string(jsonData))
-
-	if err := c.WriteMessage(websocket.TextMessage, jsonData); err != nil {
-		fmt.Printf("ERROR while sending players as websocket-message: %v", err)
-		return
-	}
+	b.SendLossy(jsonData)
 }
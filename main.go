@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/algo7/tf2_rcon_misc/logger"
-	"github.com/gorilla/websocket"
 	"os"
 	"os/signal"
 	"strconv"
@@ -11,9 +13,16 @@ import (
 	"time"
 
 	"github.com/algo7/tf2_rcon_misc/commands"
+	"github.com/algo7/tf2_rcon_misc/config"
 	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/detection"
+	"github.com/algo7/tf2_rcon_misc/events"
 	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/session"
+	"github.com/algo7/tf2_rcon_misc/state"
 	"github.com/algo7/tf2_rcon_misc/utils"
+	"github.com/algo7/tf2_rcon_misc/votekick"
+	"github.com/nxadm/tail"
 )
 
 // Create a new instance of the logger.
@@ -22,56 +31,89 @@ var log = logger.Logger
 // Const console message that informs you about forceful auto-balance.
 //const teamSwitchMessage = "You have switched to team BLU and will receive 500 experience points at the end of the round for changing teams."
 
-// playersInGame is a slice of player info cache struct that holds the player info
-var playersInGame []*utils.PlayerInfo
+// playersInGame holds the players currently on the server
+var playersInGame = state.NewPlayerRegistry()
 
-// Holds the last tf_lobby_debug response for usage.
-var lastLobbyDebugResponse string
-var lastUpdate int64
 var currentPlayer string
 
-var websocketConnection *websocket.Conn
-var triggerWebsocketPlayerUpdate = false
+// serverSession tracks the server we are currently connected to
+var serverSession = session.NewTracker()
+
+// detector evaluates the detection rules, it is set up before the consumers are subscribed
+var detector *detection.Engine
+
+// kicker calls the kick votes requested by the UI-Client
+var kicker = votekick.NewKicker(playersInGame)
+
+// shutdownTimeout is how long the UI-Clients, RCON and the database get to finish up on shutdown
+const shutdownTimeout = 20 * time.Second
+
+// errExitRequested is the shutdown reason when a UI-Client sent exit
+var errExitRequested = errors.New("exit requested by a UI-Client")
 
 func main() {
-	signals := setupSignalHandler()
+	// Subcommands come before all flags
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
 
-	// Goroutine to handle signals
-	go func() {
-		sig := <-signals
-		log.Println("Signal received:", sig)
+	if len(os.Args) > 1 && os.Args[1] == "names" {
+		runNames(os.Args[2:])
+		return
+	}
 
-		// Notify the main logic to shut down
-		// Perform cleanup or shutdown procedures
-		log.Println("Performing graceful shutdown.")
+	if len(os.Args) > 1 && os.Args[1] == "playerlist" {
+		runPlayerlist(os.Args[2:])
+		return
+	}
 
-		if network.HttpServer != nil {
-			_ = network.HttpServer.Close()
-			log.Println("HttpServer closed.")
-		}
+	// Load the configuration from file, environment and flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Unable to load the configuration: %v", err)
+	}
 
-		os.Exit(0)
-	}()
+	openDatabase(cfg.Database)
 
-	// Start websocket for IPC with UI-Client
+	// Everything stops once a signal or a UI-Client tells us to
+	ctx, cancel := shutdownContext()
+	defer cancel()
+
+	// Start websocket for IPC with UI-Client, logs go to all clients
+	log.SetBroadcaster(network.Clients)
+	registerRequestHandlers()
 	go network.StartWebsocket(27689, onWebsocketConnectCallback)
 
-	websocketPlayerUpdaterTicker := startWebsocketPlayerUpdater()
+	go startWebsocketPlayerUpdater(ctx)
+
+	// The UI-Client follows all events from the start, including the RCON connection status
+	bus := events.NewBus()
+	subscribeWebsocket(bus)
+	network.OnStatus(func(change network.StatusChange) {
+		bus.Publish(events.RconStatus{Info: rconStatusInfo(change)})
+	})
 
 	// Init the grok patterns
 	utils.GrokInit()
 
-	// Connect to the rcon server
-	network.Connect()
+	// Known offenders are highlighted as soon as they appear
+	loadMarks()
+	loadDetector(cfg.Detection.RulesFile)
 
-	if network.RCONConnection == nil {
-		log.Println("Connection to RCON failed")
+	// Chat commands must not get us kicked for spam
+	commands.Configure(cfg.Commands)
+
+	// Connect to the rcon server, blocks until connected
+	network.Configure(cfg.Rcon)
+	if err := network.Connect(ctx); err != nil {
+		shutdown(ctx, nil, bus)
+		return
 	}
 
 	// Get the current player name
 	res := network.RconExecute("name")
 
-	err := error(nil)
 	currentPlayer, err = utils.GrokParsePlayerName(res)
 
 	if err != nil {
@@ -79,6 +121,7 @@ func main() {
 	}
 
 	log.Printf("Current player is '%s'", currentPlayer)
+	resolveMe(network.RconExecute("status"))
 
 	// Get log path
 	tf2LogPath := utils.LogPathDection()
@@ -97,199 +140,360 @@ func main() {
 		log.Fatalf("Unable to tail the log file: %v", err)
 	}
 
+	// Wire up all other consumers of the console events
+	subscribeConsumers(bus, true)
+
 	// Start player watcher.
-	go startUpdatePlayerWatcher()
+	go startUpdatePlayerWatcher(ctx)
 
 	// Loop through the text of each received line
-	for line := range t.Lines {
+	followLog(ctx, t, bus)
 
-		// Refresh player list logic
-		// Don't assume status headlines as player connects
-		if strings.Contains(line.Text, "Lobby updated") || (strings.Contains(line.Text, "connected") && !strings.Contains(line.Text, "uniqueid")) {
-			log.Printf("Executing *status* + *tf_lobby_debug* command after line: %s", line.Text)
+	shutdown(ctx, t, bus)
+}
 
-			// Run the status command when the lobby is updated or a player connects
-			network.RconExecute("status")
-			lastLobbyDebugResponse = network.RconExecute("tf_lobby_debug")
+// followLog publishes every line of the tailed log until the context is cancelled
+func followLog(ctx context.Context, t *tail.Tail, bus *events.Bus) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-t.Lines:
+			if !ok {
+				log.Printf("Stopped tailing the log file: %v", t.Err())
+				return
+			}
+			publishLine(bus, line.Text)
 		}
+	}
+}
 
-		// Parse the line for player info
-		if playerInfo, err := utils.GrokParse(line.Text); err == nil {
-			// log.Printf("%+v\n", *playerInfo)
+// shutdownContext returns the context of the program, it is cancelled on SIGINT/SIGTERM or when a UI-Client sends exit.
+// A second signal exits right away.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
 
-			// Append the player to the player list
-			updatePlayers(playerInfo)
-			expirePlayers()
+	signals := setupSignalHandler()
 
-			// Create a player document for inserting into MongoDB
-			player := db.Player{
-				SteamID:   playerInfo.SteamID,
-				Name:      playerInfo.Name,
-				UpdatedAt: time.Now().UnixNano(),
-			}
+	// Goroutine to handle signals
+	go func() {
+		sig := <-signals
+		log.Println("Signal received:", sig)
+		cancel(fmt.Errorf("received %s", sig))
 
-			// Add the player to the DB
-			db.AddPlayer(player)
-			triggerWebsocketPlayerUpdate = true
-		}
+		sig = <-signals
+		log.Println("Signal received again, exiting right away:", sig)
+		os.Exit(1)
+	}()
 
-		// Parse the line for chat info
-		if chat, err := utils.GrokParseChat(line.Text); err == nil {
+	network.OnExit(func() {
+		cancel(errExitRequested)
+	})
 
-			log.Printf("Chat: %+v\n", *chat)
+	return ctx, func() { cancel(context.Canceled) }
+}
 
-			// Parse the chat message for commands
-			if command, args, err := utils.GrokParseCommand(chat.Message); err == nil {
-				commands.CommandExecuted(command, args, chat.PlayerName, currentPlayer)
-			}
+// shutdown stops in order: the log first so no new events come in, then the current session is ended,
+// the UI-Clients are told and RCON is closed. The queued database writes go last.
+// t may be nil if we never got to tail the log.
+func shutdown(ctx context.Context, t *tail.Tail, bus *events.Bus) {
+	reason := "shutting down"
+	if cause := context.Cause(ctx); cause != nil {
+		reason = cause.Error()
+	}
+	log.Printf("Performing graceful shutdown (%s).", reason)
 
-			// Get the player's steamID64 from the playersInGame
-			steamID, err := utils.GetSteamIDFromPlayerName(chat.PlayerName, playersInGame)
-
-			if err == nil {
-				// Create a chat document for inserting into MongoDB
-				chatInfo := db.Chat{
-					SteamID:   steamID,
-					Name:      chat.PlayerName,
-					Message:   chat.Message,
-					UpdatedAt: time.Now().UnixNano(),
-				}
-				db.AddChat(chatInfo)
-			}
+	if t != nil {
+		if err := t.Stop(); err != nil {
+			log.Printf("Error stopping the log tail: %v", err)
 		}
+		t.Cleanup()
+	}
+
+	// We are leaving the server as well, the database and the UI-Clients get to see the end of the session
+	if ended := serverSession.End(); ended != nil {
+		bus.Publish(events.ServerInfo{Session: *ended})
+	}
 
-		// Parse the line for kill info
-		if frag, err := utils.GrokParseFrag(line.Text); err == nil {
+	timeout, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-			// Get the player's steamID64 from the playersInGame
-			killerSteamID, err := utils.GetSteamIDFromPlayerName(frag.KillerName, playersInGame)
-			if err != nil {
-				log.Printf("Error finding steam-id for player %s: %v", frag.KillerName, err)
-			}
+	network.StopWebsocket(timeout, reason)
+	network.Close()
 
-			victimSteamID, err := utils.GetSteamIDFromPlayerName(frag.VictimName, playersInGame)
-			if err != nil {
-				log.Printf("Error finding steam-id for player %s: %v", frag.VictimName, err)
-			}
+	// The file database keeps recent changes in memory
+	if err := db.Close(); err != nil {
+		log.Printf("Error closing the database: %v", err)
+	}
 
-			frag.VictimSteamID = strconv.FormatInt(victimSteamID, 10)
-			frag.KillerSteamID = strconv.FormatInt(killerSteamID, 10)
-
-			//log.Printf("Frag: %+v\n", *frag)
-			network.SendFrag(websocketConnection, frag)
-
-			//// Get the player's steamID64 from the playersInGame
-			//steamIDKiller, err := utils.GetSteamIDFromPlayerName(frag.KillerName, playersInGame)
-			//steamIDVictim, err := utils.GetSteamIDFromPlayerName(frag.VictimName, playersInGame)
-
-			// TODO, add frags to db
-			//if err == nil {
-			//	// Create a frag document for inserting into MongoDB
-			//	fragInfo := db.Frag{
-			//		SteamIDKiller: steamIDKiller,
-			//		SteamIDVictim: steamIDVictim,
-			//		Killer:        frag.KillerName,
-			//		VictimName:    frag.VictimName,
-			//		UpdatedAt:     time.Now().UnixNano(),
-			//	}
-			//	db.AddFrag(chatInfo)
-			//}
-		}
+	log.Println("Shutdown complete.")
+}
+
+// subscribeConsumers wires up all consumers of the console events, withDB also stores them in the database
+func subscribeConsumers(bus *events.Bus, withDB bool) {
+	subscribePlayers(bus)
+	if withDB {
+		db.Subscribe(bus)
+	}
+	commands.Subscribe(bus, playersInGame)
+	votekick.Subscribe(bus, kicker)
+	detection.Subscribe(bus, detector, playersInGame)
+}
+
+// openDatabase opens the configured database, all db functions use it from then on
+func openDatabase(cfg config.Database) {
+	err := db.Open(db.Options{Backend: cfg.Backend, Path: cfg.Path, URI: cfg.URI, Name: cfg.Name})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// openConfiguredDatabase opens the database configured by the config file and the environment, for the subcommands
+func openConfiguredDatabase() {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("Unable to load the configuration: %v", err)
+	}
+
+	openDatabase(cfg.Database)
+}
+
+// loadDetector sets up the detector with the rules of the given file
+func loadDetector(rulesFile string) {
+	rules, err := detection.LoadRules(rulesFile)
+	if err != nil {
+		log.Fatalf("Unable to load the detection rules: %v", err)
+	}
+
+	detector, err = detection.NewEngine(rules)
+	if err != nil {
+		log.Fatalf("Invalid detection rule: %v", err)
 	}
 
-	defer websocketPlayerUpdaterTicker.Stop()
+	log.Printf("Loaded %d detection rules", len(rules))
 }
 
-// expirePlayers scan all players and discard players that haven't been here for >=20 seconds
-func expirePlayers() {
-	var activePlayers []*utils.PlayerInfo
-	currentTime := time.Now().Unix()
+// publishLine parses a single console line and publishes the resulting events on the bus
+func publishLine(bus *events.Bus, line string) {
+	// Parse the line for chat info first, players type whatever they want in chat.
+	// Nothing in a chat line may pass for a console message like a frag or a refused vote.
+	if chat, err := utils.GrokParseChat(line); err == nil {
+		log.Printf("Chat: %+v\n", *chat)
 
-	for _, existingPlayer := range playersInGame {
-		// If player was seen in the last 20 seconds, keep him
-		if existingPlayer.LastSeen+20 >= currentTime {
-			activePlayers = append(activePlayers, existingPlayer)
+		current, _ := serverSession.Current()
+
+		// Get the player's steamID64 from the playersInGame, 0 if unknown
+		steamID := lookupSteamID(chat.PlayerName)
+		bus.Publish(events.ChatMessage{Chat: chat, SteamID: steamID, SessionID: current.ID})
+		return
+	}
+
+	// Refresh player list logic
+	// Don't assume status headlines as player connects
+	if strings.Contains(line, "Lobby updated") {
+		bus.Publish(events.LobbyUpdated{Line: line})
+	} else if strings.Contains(line, "connected") && !strings.Contains(line, "uniqueid") {
+		bus.Publish(events.Connected{Line: line})
+	}
+
+	// Keep track of the server we are playing on
+	for _, changed := range serverSession.Update(line) {
+		bus.Publish(events.ServerInfo{Session: changed})
+	}
+
+	current, _ := serverSession.Current()
+
+	// Parse the line for player info
+	if playerInfo, err := utils.GrokParse(line); err == nil {
+		bus.Publish(events.PlayerSeen{Player: playerInfo, SessionID: current.ID, Server: current.Address, Hostname: current.Hostname})
+	}
+
+	// Parse the line for kill info
+	if frag, err := utils.GrokParseFrag(line); err == nil {
+
+		// Get the player's steamID64 from the playersInGame
+		killerSteamID := lookupSteamID(frag.KillerName)
+		if killerSteamID == 0 {
+			log.Printf("Error finding steam-id for player %s", frag.KillerName)
+		}
+
+		victimSteamID := lookupSteamID(frag.VictimName)
+		if victimSteamID == 0 {
+			log.Printf("Error finding steam-id for player %s", frag.VictimName)
+		}
+
+		frag.VictimSteamID = strconv.FormatInt(victimSteamID, 10)
+		frag.KillerSteamID = strconv.FormatInt(killerSteamID, 10)
+
+		bus.Publish(events.Frag{Info: frag, Map: current.Map, SessionID: current.ID})
+	}
+
+	// Parse the line for suicide info
+	if suicide, err := utils.GrokParseSuicide(line); err == nil {
+
+		// Get the player's steamID64 from the playersInGame
+		steamID := lookupSteamID(suicide.PlayerName)
+		if steamID == 0 {
+			log.Printf("Error finding steam-id for player %s", suicide.PlayerName)
 		}
+
+		suicide.SteamID = strconv.FormatInt(steamID, 10)
+
+		bus.Publish(events.Suicide{Info: suicide, SessionID: current.ID})
 	}
 
-	// Replace the old playersInGame slice with the new activePlayers slice
-	playersInGame = activePlayers
+	// Parse the line for a refused vote
+	if wait, err := utils.GrokParseVoteCooldown(line); err == nil {
+		bus.Publish(events.VoteCooldown{Wait: wait})
+	}
 }
 
-// Update player collection with supplied new playerInfo entity.
-func updatePlayers(playerInfo *utils.PlayerInfo) {
-	var lobbyPlayers []utils.LobbyDebugPlayer
+// subscribePlayers keeps the player cache up to date and refreshes it whenever the lobby changes
+func subscribePlayers(bus *events.Bus) {
+	bus.Subscribe(func(e events.Event) {
+		log.Printf("Executing *status* + *tf_lobby_debug* command after event: %s", e.Type())
+
+		// Run the status command when the lobby is updated or a player connects, its output arrives in the log
+		network.RconQueue("status", network.PriorityNormal)
+		playersInGame.SetLobbyDebug(network.RconExecute("tf_lobby_debug"))
+	}, events.TypeLobbyUpdated, events.TypeConnected)
+
+	bus.Subscribe(func(e events.Event) {
+		seen := e.(events.PlayerSeen)
 
-	if "Failed to find lobby shared object" != lastLobbyDebugResponse {
-		// log.Println("debug-response: " + lastLobbyDebugResponse)
-		lobbyPlayers = utils.ParseLobbyResponse(lastLobbyDebugResponse)
+		// Append the player to the player list, discard players that haven't been here for 20 seconds
+		_, known := playersInGame.LookupBySteamID(seen.Player.SteamID)
+		playersInGame.Upsert(seen.Player)
+		forgetEncounters(playersInGame.Expire(20 * time.Second))
+
+		// A whole listing went by since we were seen first, a copier of our name would be known by now
+		if known && seen.Player.Name == currentPlayer && len(playersInGame.Named(currentPlayer)) == 1 {
+			setMe(seen.Player)
+		}
+
+		// Tell the UI-Client whether we played with them before
+		loadEncounters(seen.Player.SteamID, seen.SessionID)
+	}, events.TypePlayerSeen)
+}
+
+// lookupSteamID gets the steamID64 of the player who wrote a line with the given name from the playersInGame, 0 if unknown.
+// A name shared by several players is attributed by join order, see PlayerRegistry.Attribute.
+func lookupSteamID(playerName string) int64 {
+	player, candidates := playersInGame.Attribute(playerName, time.Now())
+	if player == nil {
+		return 0
 	}
 
-	// Find ourselves and set flag to true.
-	if playerInfo.Name == currentPlayer {
-		playerInfo.IsMe = true
-	} else {
-		playerInfo.IsMe = false
+	if len(candidates) > 1 {
+		steamIDs := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			steamIDs[i] = fmt.Sprintf("#%d %d", candidate.UserID, candidate.SteamID)
+		}
+		log.Printf("Name '%s' is shared by %s (in join order), attributing the line to the newest #%d", playerName, strings.Join(steamIDs, ", "), player.UserID)
 	}
 
-	lobbyPlayer := utils.FindLobbyPlayerBySteamId(lobbyPlayers, playerInfo.SteamID)
+	return player.SteamID
+}
 
-	if lobbyPlayer != nil {
-		//log.Printf("dbg: %+v\n", lobbyPlayer)
-		playerInfo.Team = lobbyPlayer.Team
-		playerInfo.Type = lobbyPlayer.Type
-		playerInfo.MemberType = lobbyPlayer.MemberType
+// resolveMe finds our own steamID64 in a complete status listing, nothing happens once it is known.
+// The name alone can't tell us apart from someone who copied it, so it waits until nobody else uses our name.
+func resolveMe(status string) {
+	if playersInGame.MySteamID() != 0 {
+		return
 	}
 
-	// Check if the player already exists in the list
-	for i, existingPlayer := range playersInGame {
-		if existingPlayer.SteamID == playerInfo.SteamID {
-			// Player already exists, update the fields
-			// Preserve tf-lobby-fields if new ones are empty
-			if len(playerInfo.Team) <= 0 {
-				playerInfo.Team = playersInGame[i].Team
-			}
+	var mine []*utils.PlayerInfo
+	for _, line := range strings.Split(status, "\n") {
+		if player, err := utils.GrokParse(line); err == nil && player.Name == currentPlayer {
+			mine = append(mine, player)
+		}
+	}
 
-			if len(playerInfo.Type) <= 0 {
-				playerInfo.Team = playersInGame[i].Type
-			}
+	switch len(mine) {
+	case 0:
+		return
+	case 1:
+		setMe(mine[0])
+	default:
+		log.Printf("Name '%s' is used by %d players, waiting for the next status to tell which one is us", currentPlayer, len(mine))
+	}
+}
 
-			if len(playerInfo.MemberType) <= 0 {
-				playerInfo.Team = playersInGame[i].MemberType
-			}
+// setMe makes the player our own, unless we are known already
+func setMe(player *utils.PlayerInfo) {
+	if playersInGame.MySteamID() != 0 {
+		return
+	}
 
-			playersInGame[i] = playerInfo
-			return
+	log.Printf("Current player '%s' is %d", currentPlayer, player.SteamID)
+	playersInGame.SetMe(player.SteamID)
+}
+
+// subscribeWebsocket forwards frags, suicides, server-info, rcon-status and detections to all UI-Clients
+func subscribeWebsocket(bus *events.Bus) {
+	bus.Subscribe(func(e events.Event) {
+		switch e := e.(type) {
+		case events.Frag:
+			network.SendFrag(network.Clients, e.Info)
+		case events.Suicide:
+			network.SendSuicide(network.Clients, e.Info)
+		case events.ServerInfo:
+			network.SendServerInfo(network.Clients, &e.Session)
+		case events.RconStatus:
+			network.SendRconStatus(network.Clients, e.Info)
+		case events.Detection:
+			network.SendDetection(network.Clients, e.Info)
 		}
+	}, events.TypeFrag, events.TypeSuicide, events.TypeServerInfo, events.TypeRconStatus, events.TypeDetection)
+}
+
+// rconStatusInfo converts a status change of the RCON connection for the UI-Client
+func rconStatusInfo(change network.StatusChange) *utils.RconStatusInfo {
+	info := &utils.RconStatusInfo{
+		Status:  string(change.Status),
+		Attempt: change.Attempt,
+		RetryIn: change.Retry.Milliseconds(),
 	}
 
-	// If playerInfo is $ME, mark it as me.
-	if playerInfo.Name == currentPlayer {
-		playerInfo.IsMe = true
+	if change.Error != nil {
+		info.Error = change.Error.Error()
 	}
 
-	playersInGame = append(playersInGame, playerInfo)
-	lastUpdate = time.Now().Unix()
+	return info
 }
 
-// onWebsocketConnectCallback Callback that is called once websocket-connection has been established.
-func onWebsocketConnectCallback(c *websocket.Conn) {
-	websocketConnection = c
-	log.SetWsConnection(websocketConnection)
-	network.SendPlayers(c, playersInGame)
+// onWebsocketConnectCallback Callback that is called once websocket-connection has been established, catches the new client up.
+func onWebsocketConnectCallback(c *network.Client) {
+	network.SendPlayers(c, playersInGame.Snapshot())
+
+	if current, ok := serverSession.Current(); ok {
+		network.SendServerInfo(c, &current)
+	}
+
+	network.SendRconStatus(c, rconStatusInfo(network.CurrentStatus()))
 }
 
-// startUpdatePlayerWatcher Initializes player updates every 10 seconds if there have been none.
-func startUpdatePlayerWatcher() {
+// startUpdatePlayerWatcher Initializes player updates every 10 seconds if there have been none, until the context is cancelled.
+func startUpdatePlayerWatcher(ctx context.Context) {
 	for {
 		// Sleep for 10 seconds
-		time.Sleep(10 * time.Second)
+		select {
+		case <-time.After(10 * time.Second):
+		case <-ctx.Done():
+			return
+		}
 
 		// Check when last update happened.
+		lastUpdate := playersInGame.LastUpdate()
 		if (lastUpdate + 10) < time.Now().Unix() {
 			log.Println("Executing *status* + *tf_lobby_debug* command after scheduled 10s")
-			lastLobbyDebugResponse = network.RconExecute("tf_lobby_debug")
-			network.RconExecute("status")
+			playersInGame.SetLobbyDebug(network.RconExecute("tf_lobby_debug"))
+			status := network.RconQueue("status", network.PriorityLow)
+
+			// We may have started outside of a game
+			if playersInGame.MySteamID() == 0 {
+				resolveMe(status.Wait())
+			}
 		} else {
 			log.Printf("No update necessary, last one happened '%d' seconds ago!\n", time.Now().Unix()-lastUpdate)
 		}
@@ -308,37 +512,23 @@ func setupSignalHandler() chan os.Signal {
 
 // sendPlayerUpdateWebsocket send the player-update over websockets.
 func sendPlayerUpdateWebsocket() {
-	// When websocket connected, send over the new players
-	if triggerWebsocketPlayerUpdate && websocketConnection != nil {
-		for _, playerInfo := range playersInGame {
-			if len(playerInfo.Team) > 0 {
-				//log.Printf("sendPlayerUpdateWebsocket() non-empty-player: %v\n", playerInfo)
-			} else {
-				//log.Printf("sendPlayerUpdateWebsocket() empty-player: %v\n", playerInfo)
-			}
-		}
-
-		network.SendPlayers(websocketConnection, playersInGame)
-		triggerWebsocketPlayerUpdate = false
+	// When websocket clients are connected, send over the new players
+	if network.Clients.Len() > 0 && playersInGame.TakeDirty() {
+		network.SendPlayers(network.Clients, playersInGame.Snapshot())
 	}
 }
 
-// startWebsocketPlayerUpdater start regular player-updater
-func startWebsocketPlayerUpdater() *time.Ticker {
+// startWebsocketPlayerUpdater runs the regular player-updater until the context is cancelled
+func startWebsocketPlayerUpdater(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
 
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				sendPlayerUpdateWebsocket()
-			}
+	for {
+		select {
+		case <-ticker.C:
+			sendPlayerUpdateWebsocket()
+		case <-ctx.Done():
+			return
 		}
-	}()
-
-	return ticker
-}
-
-func GetWebsocketConnection() *websocket.Conn {
-	return websocketConnection
+	}
 }
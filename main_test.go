@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// TestPublishLine checks that chat is parsed first, nothing typed in chat may pass for a frag, a suicide or a refused vote
+func TestPublishLine(t *testing.T) {
+	utils.GrokInit()
+
+	cases := []struct {
+		line string
+		want []events.Type
+	}{
+		{"atomy suicided.", []events.Type{events.TypeSuicide}},
+		{"atomy killed bob with scattergun. (crit)", []events.Type{events.TypeFrag}},
+		{"Wait 5 seconds before calling another vote.", []events.Type{events.TypeVoteCooldown}},
+		{"bob :  I suicided.", []events.Type{events.TypeChatMessage}},
+		{"*DEAD* bob :  atomy killed bob with scattergun.", []events.Type{events.TypeChatMessage}},
+		{"*DEAD*(TEAM) bob :  atomy suicided.", []events.Type{events.TypeChatMessage}},
+		{"(TEAM) bob :  Wait 5 seconds before calling another vote.", []events.Type{events.TypeChatMessage}},
+	}
+
+	for _, c := range cases {
+		var published []events.Type
+
+		bus := events.NewBus()
+		bus.Subscribe(func(e events.Event) {
+			published = append(published, e.Type())
+		}, events.TypeChatMessage, events.TypeFrag, events.TypeSuicide, events.TypeVoteCooldown)
+
+		publishLine(bus, c.line)
+
+		if !reflect.DeepEqual(published, c.want) {
+			t.Errorf("publishLine(%q) published %v, want %v", c.line, published, c.want)
+		}
+	}
+}
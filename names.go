@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+)
+
+// runNames prints the name history of the players with the given steamID64 or name
+func runNames(args []string) {
+	flags := flag.NewFlagSet("names", flag.ExitOnError)
+	limit := flags.Int64("limit", 50, "maximum number of names per player")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: names [flags] <steamID64|name>")
+	}
+	query := flags.Arg(0)
+
+	openConfiguredDatabase()
+	defer func() {
+		_ = db.Close()
+	}()
+
+	steamIDs, err := db.FindSteamIDsByName(query)
+	if err != nil {
+		log.Fatalf("Unable to find the players named '%s': %v", query, err)
+	}
+
+	if steamID, err := strconv.ParseInt(query, 10, 64); err == nil {
+		steamIDs = append([]int64{steamID}, steamIDs...)
+	}
+
+	if len(steamIDs) == 0 {
+		log.Fatalf("No player ever used the name '%s'", query)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, steamID := range steamIDs {
+		names, err := db.FindNames(steamID, *limit)
+		if err != nil {
+			log.Fatalf("Unable to find the names of %d: %v", steamID, err)
+		}
+
+		_, _ = fmt.Fprintf(w, "%d\t%d names\n", steamID, len(names))
+		for _, name := range names {
+			_, _ = fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\n", name.Name, formatUnix(name.FirstSeen), formatUnix(name.LastSeen), name.Hostname)
+		}
+	}
+	_ = w.Flush()
+}
+
+// formatUnix formats the unix timestamp in local time
+func formatUnix(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04")
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gorcon/rcon"
+)
+
+// authID is the packet ID of the authentication request
+const authID = 0
+
+// rconConn is a Source RCON connection that reads responses split over several packets.
+// The game splits responses larger than one packet, a full `status` for example. rcon.Conn only reads the first
+// packet and returns the rest as the response to the next command.
+type rconConn struct {
+	conn     net.Conn
+	deadline time.Duration
+	// lastID is the packet ID of the last command, every command and its end marker get new IDs
+	lastID int32
+}
+
+// dialRcon connects to the address and authenticates with the password
+func dialRcon(address string, password string, dialTimeout time.Duration) (*rconConn, error) {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("rcon: %w", err)
+	}
+
+	c := &rconConn{conn: conn, deadline: rcon.DefaultDeadline}
+	if err := c.auth(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// auth sends the password, the game answers with an empty response packet first and then the result
+func (c *rconConn) auth(password string) error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.deadline)); err != nil {
+		return fmt.Errorf("rcon: %w", err)
+	}
+
+	if _, err := rcon.NewPacket(rcon.SERVERDATA_AUTH, authID, password).WriteTo(c.conn); err != nil {
+		return err
+	}
+
+	for {
+		response := &rcon.Packet{}
+		if _, err := response.ReadFrom(c.conn); err != nil {
+			return err
+		}
+
+		if response.Type != rcon.SERVERDATA_AUTH_RESPONSE {
+			continue
+		}
+
+		if response.ID == -1 {
+			return rcon.ErrAuthFailed
+		}
+
+		return nil
+	}
+}
+
+// Execute runs the command and returns its response, the parts of a response split over several packets are joined.
+// An empty response packet is sent after the command, the game answers it after the last part of the response.
+func (c *rconConn) Execute(command string) (string, error) {
+	if command == "" {
+		return "", rcon.ErrCommandEmpty
+	}
+
+	if len(command) > rcon.MaxCommandLen {
+		return "", rcon.ErrCommandTooLong
+	}
+
+	c.lastID += 2
+	id, endID := c.lastID-1, c.lastID
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.deadline)); err != nil {
+		return "", fmt.Errorf("rcon: %w", err)
+	}
+
+	if _, err := rcon.NewPacket(rcon.SERVERDATA_EXECCOMMAND, id, command).WriteTo(c.conn); err != nil {
+		return "", err
+	}
+	if _, err := rcon.NewPacket(rcon.SERVERDATA_RESPONSE_VALUE, endID, "").WriteTo(c.conn); err != nil {
+		return "", err
+	}
+
+	var response strings.Builder
+	for {
+		packet := &rcon.Packet{}
+		if _, err := packet.ReadFrom(c.conn); err != nil {
+			return response.String(), err
+		}
+
+		switch packet.ID {
+		case id:
+			response.WriteString(packet.Body())
+		case endID:
+			return response.String(), nil
+		}
+
+		// Anything else is left over from an earlier command, like the second answer to its end marker
+	}
+}
+
+// Close closes the connection
+func (c *rconConn) Close() error {
+	return c.conn.Close()
+}
@@ -1,29 +1,59 @@
 package network
 
 import (
+	"encoding/json"
+	"github.com/algo7/tf2_rcon_misc/config"
 	"github.com/algo7/tf2_rcon_misc/logger"
-	"github.com/gorcon/rcon"
 	"github.com/gorilla/websocket"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // Create a new instance of the logger.
 var log = logger.Logger
 
-// Global variables
+// supervisor owns the RCON connection
+var supervisor = NewSupervisor()
+
+// Executor executes RCON commands, the RCON connection implements it
+type Executor interface {
+	Execute(command string) (string, error)
+}
+
+// stubExecutor replaces the RCON connection when set, see UseExecutor
+var stubExecutor Executor
+
+// rconConfig holds the RCON settings, see Configure
+var rconConfig = config.Default().Rcon
+
+// commandQueue serializes all RCON commands, its worker is started on first use and stopped by Close
 var (
-	rconHost       string
-	RCONConnection *rcon.Conn
+	commandQueue = newCommandQueue()
+	startQueue   sync.Once
+	stopQueue    sync.Once
+	queueStop    = make(chan struct{})
 )
 
-const (
-	rconPort = 27015
-)
+// newCommandQueue creates the command queue with the default rate limits
+func newCommandQueue() *Queue {
+	queue := NewQueue(execute)
+	queue.SetRateLimit("say", time.Duration(rconConfig.SayInterval))
+	return queue
+}
 
-type CallbackFunc func(*websocket.Conn)
+// CallbackFunc is called with every newly connected websocket client
+type CallbackFunc func(*Client)
 
+// Clients holds all connected websocket clients
+var Clients = NewHub()
+
+// Message is a message sent by a UI-Client, requests carry an ID that is mirrored in the response
 type Message struct {
-	Type string `json:"type"`
+	Version int             `json:"version,omitempty"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
 const wsPath = "/websocket"
@@ -39,3 +69,6 @@ var upgrader = websocket.Upgrader{
 }
 
 var onConnectCallback CallbackFunc
+
+// onExitCallback is called when a UI-Client asks us to exit, see OnExit
+var onExitCallback func()
@@ -0,0 +1,187 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// clientQueueSize is the number of messages a client may fall behind before it is dropped
+	clientQueueSize = 256
+	// writeTimeout is the time a client gets to accept a single message
+	writeTimeout = 10 * time.Second
+)
+
+// Sender delivers websocket messages, either to a single *Client or to all clients of a *Hub
+type Sender interface {
+	Send(data []byte)
+}
+
+// Client is a connected websocket client with its own send queue.
+// Send never blocks, a client that falls too far behind is disconnected. Messages that may be lost, like log lines,
+// go through SendLossy instead and only fill half of the queue, so the other messages always find room.
+type Client struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	mu     sync.Mutex
+	closed bool
+	// done is closed once the writer stopped
+	done chan struct{}
+}
+
+// Send queues the message for the client
+func (c *Client) Send(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.send <- data:
+	default:
+		// Too slow, drop the client instead of stalling everyone else
+		c.closed = true
+		close(c.send)
+		_ = c.conn.Close()
+	}
+}
+
+// SendLossy queues the message unless the client is behind, it is dropped then
+func (c *Client) SendLossy(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.send) >= clientQueueSize/2 {
+		return
+	}
+
+	c.send <- data
+}
+
+// RemoteAddr returns the address of the client
+func (c *Client) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// close stops the send queue
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// writePump is the only writer of the connection, it drains the send queue until it is closed and then says goodbye
+func (c *Client) writePump() {
+	defer close(c.done)
+
+	for data := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			// Unblock the reader, the handler cleans up
+			_ = c.conn.Close()
+			c.close()
+			return
+		}
+	}
+
+	// Fails if the connection is gone already, nothing to do about that
+	goodbye := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+	_ = c.conn.WriteControl(websocket.CloseMessage, goodbye, time.Now().Add(writeTimeout))
+}
+
+// Hub tracks all connected websocket clients and fans messages out to them
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+}
+
+// NewHub creates a hub without clients
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*Client]struct{})}
+}
+
+// Send queues the message for every connected client
+func (h *Hub) Send(data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		client.Send(data)
+	}
+}
+
+// SendLossy queues the message for every connected client that is not behind
+func (h *Hub) SendLossy(data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		client.SendLossy(data)
+	}
+}
+
+// Len returns the number of connected clients
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients)
+}
+
+// register adds the connection as client and starts its writer
+func (h *Hub) register(conn *websocket.Conn) *Client {
+	client := &Client{
+		conn: conn,
+		send: make(chan []byte, clientQueueSize),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	go client.writePump()
+
+	return client
+}
+
+// unregister removes the client and stops its writer
+func (h *Hub) unregister(client *Client) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+
+	client.close()
+}
+
+// Close stops the send queues of all clients and waits until the queued messages were written or the context ended
+func (h *Hub) Close(ctx context.Context) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.close()
+	}
+
+	for _, client := range clients {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
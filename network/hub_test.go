@@ -0,0 +1,24 @@
+package network
+
+import "testing"
+
+// TestLogBurstKeepsClient floods a client with log lines, the other messages must still find room
+func TestLogBurstKeepsClient(t *testing.T) {
+	client := &Client{send: make(chan []byte, clientQueueSize)}
+
+	for i := 0; i < 10*clientQueueSize; i++ {
+		client.SendLossy([]byte("log"))
+	}
+
+	if queued := len(client.send); queued != clientQueueSize/2 {
+		t.Fatalf("%d log lines queued, want them limited to %d", queued, clientQueueSize/2)
+	}
+
+	for i := 0; i < clientQueueSize/2; i++ {
+		client.Send([]byte("player-update"))
+	}
+
+	if client.closed {
+		t.Fatal("the client was disconnected although the log lines left room for the other messages")
+	}
+}
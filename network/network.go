@@ -1,19 +1,27 @@
 package network
 
 import (
+	"context"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gorcon/rcon"
+	"github.com/algo7/tf2_rcon_misc/config"
 )
 
+// Configure sets the RCON host, port, password and dial timeout used by Connect and the rate limit for chat output
+func Configure(cfg config.Rcon) {
+	rconConfig = cfg
+	commandQueue.SetRateLimit("say", time.Duration(cfg.SayInterval))
+}
+
 // scanPort scans for the given port on the host
 func scanPort(protocol, hostname string, port int) bool {
 	log.Printf("Connecting to: %s:%d\n", hostname, port)
 	address := hostname + ":" + strconv.Itoa(port)
-	RCONConnection, err := net.DialTimeout(protocol, address, 60*time.Second)
+	RCONConnection, err := net.DialTimeout(protocol, address, time.Duration(rconConfig.DialTimeout))
 
 	if err != nil {
 		return false
@@ -61,7 +69,7 @@ func determineRconHost() string {
 
 	// Scan all the ip address opened rcon port and return the ip addr with an opened rcon port
 	for _, ip := range getHostInfo() {
-		open := scanPort("tcp", ip, rconPort)
+		open := scanPort("tcp", ip, rconConfig.Port)
 		if open {
 			rconHost = ip
 			break
@@ -73,98 +81,111 @@ func determineRconHost() string {
 		return ""
 	}
 
-	log.Printf("Rcon Host: %s:%d\n", rconHost, rconPort)
+	log.Printf("Rcon Host: %s:%d\n", rconHost, rconConfig.Port)
 
 	return rconHost
 }
 
 // rconConnect connects to a rcon host
-func rconConnect(rconHost string) *rcon.Conn {
+func rconConnect(rconHost string) (*rconConn, error) {
 
-	RCONConnection, err := rcon.Dial(rconHost+":"+strconv.Itoa(rconPort), "123")
+	address := rconHost + ":" + strconv.Itoa(rconConfig.Port)
+	conn, err := dialRcon(address, rconConfig.Password, time.Duration(rconConfig.DialTimeout))
 	if err != nil {
 		log.Printf("Unable to connect to the RCON host: %v", err)
-		return nil
+		return nil, err
 	}
 
-	_, err = RCONConnection.Execute("status")
+	_, err = conn.Execute("status")
 	if err != nil {
 		log.Printf("Unable to execute the initial `status` command: %v", err)
-		return nil
+		_ = conn.Close()
+		return nil, err
 	}
 
 	log.Println("RCON connection established")
 
-	return RCONConnection
+	return conn, nil
 }
 
-// RconExecute executes a rcon command
-func RconExecute(command string) string {
-
-	// log.Println("Executing: " + command)
-	response, err := RCONConnection.Execute(command)
+// UseExecutor makes RconExecute run all commands on the given executor instead of the RCON connection
+func UseExecutor(executor Executor) {
+	stubExecutor = executor
+}
 
-	// Reconnect if the connection is lost (usually when joining a server)
-	if err != nil {
-		// Ignore errors on tf_lobby_debug, they seem to happen almost always
-		// Ignore also on status, could be lobby-related, cause I was still getting errors
-		if command != "tf_lobby_debug" && command != "status" {
-			log.Printf("Unable to execute the command: %s because %v", command, err)
-			log.Println("Connection failed, retrying...")
-			rconConnect(rconHost)
-		}
-	}
+// OnStatus registers a listener for the RCON connection status
+func OnStatus(listener func(StatusChange)) {
+	supervisor.OnStatus(listener)
+}
 
-	return response
+// CurrentStatus returns the current RCON connection status
+func CurrentStatus() StatusChange {
+	return supervisor.Status()
 }
 
-// Connect tries to determine the rcon host and connect to it
-func Connect() {
+// RconQueue queues a rcon command with the given priority and returns the future of its response
+func RconQueue(command string, priority Priority) *Future {
+	startQueue.Do(func() {
+		go commandQueue.Run(queueStop)
+	})
 
-	// Set the loop duration to 5 minutes
-	duration := 5 * time.Minute
+	return commandQueue.Enqueue(command, priority)
+}
 
-	// Set the pause interval to 5 seconds
-	interval := 5 * time.Second
+// Say queues a chat line, team sends it to our own team only.
+// Quotes, semicolons and line breaks are removed as they would end the command.
+func Say(message string, team bool, priority Priority) *Future {
+	message = strings.NewReplacer("\"", "'", ";", ",", "\r", " ", "\n", " ").Replace(message)
 
-	// Set the max retries to 20
-	maxRetries := 20
+	verb := "say"
+	if team {
+		verb = "say_team"
+	}
 
-	// Try to determine the rcon host for 5 minutes
-	// Get the current time
-	start := time.Now()
-	try := 1
+	return RconQueue(verb+" \""+message+"\"", priority)
+}
 
-	for time.Since(start) < duration && try <= maxRetries {
-		rconHost = determineRconHost()
+// RconExecute executes a rcon command and waits for its response
+func RconExecute(command string) string {
+	return RconQueue(command, PriorityNormal).Wait()
+}
 
-		if rconHost == "" {
-			log.Printf("Rcon host detection failed, retrying, %d/%d tries...\n", try, maxRetries)
-			time.Sleep(interval)
-		} else {
-			break
-		}
+// execute executes a rcon command right away, only the command queue may call it
+func execute(command string) string {
 
-		try++
+	if stubExecutor != nil {
+		response, _ := stubExecutor.Execute(command)
+		return response
 	}
 
-	// Try to connect to the rcon host for 5 minutes
-	// Get the current time, reset timer
-	start = time.Now()
-	try = 1
+	// Failed connections are replaced by the supervisor
+	response, _ := supervisor.Execute(command)
 
-	for time.Since(start) < duration && try <= maxRetries {
+	return response
+}
 
-		// Connect to the rcon host
-		RCONConnection = rconConnect(rconHost)
+// Connect tries to determine the rcon host and connect to it, a configured host skips the LAN scan.
+// It blocks until connected, retrying with exponential backoff, or until the context is cancelled.
+// Reconnects stop with the context as well.
+func Connect(ctx context.Context) error {
+	if rconConfig.Host != "" {
+		log.Printf("Rcon Host (configured): %s:%d\n", rconConfig.Host, rconConfig.Port)
+	}
 
-		if RCONConnection == nil {
-			log.Printf("Rcon connection failed, retrying, %d/%d tries...\n", try, maxRetries)
-			time.Sleep(interval)
-		} else {
-			break
-		}
+	return supervisor.Connect(ctx)
+}
 
-		try++
-	}
+// Close stops the command queue and closes the RCON connection.
+// Commands queued from then on are answered right away with an empty response.
+func Close() {
+	// Start the worker if it never ran, it answers the waiting commands on its way out
+	startQueue.Do(func() {
+		go commandQueue.Run(queueStop)
+	})
+	stopQueue.Do(func() {
+		close(queueStop)
+	})
+
+	supervisor.Close()
+	log.Println("RCON connection closed.")
 }
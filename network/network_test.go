@@ -0,0 +1,187 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/config"
+	"github.com/algo7/tf2_rcon_misc/network/rcontest"
+)
+
+const testPassword = "secret"
+
+// connectTo points the RCON settings at the server and connects a fresh supervisor to it
+func connectTo(t *testing.T, server *rcontest.Server) {
+	t.Helper()
+
+	Configure(config.Rcon{
+		Host:        server.Host(),
+		Port:        server.Port(),
+		Password:    testPassword,
+		DialTimeout: config.Duration(time.Second),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	supervisor = NewSupervisor()
+	t.Cleanup(func() {
+		cancel()
+		supervisor.Close()
+	})
+
+	// The context stays alive for the reconnects, it ends with the test
+	connected := make(chan error, 1)
+	go func() {
+		connected <- Connect(ctx)
+	}()
+
+	select {
+	case err := <-connected:
+		if err != nil {
+			t.Fatalf("Connect() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect() did not connect in time")
+	}
+}
+
+// waitForCommand waits until the server received the command
+func waitForCommand(t *testing.T, server *rcontest.Server, command string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, received := range server.Commands() {
+			if received == command {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the server never received %q, got %q", command, server.Commands())
+}
+
+func TestConnectAndExecute(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+
+	connectTo(t, server)
+
+	if response := RconExecute("name"); response != rcontest.NameResponse("me") {
+		t.Fatalf("RconExecute(name) = %q, want %q", response, rcontest.NameResponse("me"))
+	}
+
+	server.Respond("tf_lobby_debug", "Failed to find lobby shared object")
+	if response := RconExecute("tf_lobby_debug"); response != "Failed to find lobby shared object" {
+		t.Fatalf("RconExecute(tf_lobby_debug) = %q", response)
+	}
+
+	if status := CurrentStatus(); status.Status != StatusConnected {
+		t.Fatalf("CurrentStatus() = %+v, want connected", status)
+	}
+}
+
+// TestMultiPacketResponse splits a status over several packets, like the game does when it doesn't fit in one
+func TestMultiPacketResponse(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+
+	status := "hostname: Uncletopia | Frankfurt | 2 | All Maps\n" +
+		"# userid name                uniqueid            connected ping loss state\n" +
+		"#    378 \"atomy\"             [U:1:259772]        00:07      156   74 active\n" +
+		"#    379 \"me\"                [U:1:259773]        00:07      56    0 active\n"
+
+	// The game splits at a size limit, not at line breaks
+	server.RespondMulti("status", status[:60], status[60:150], status[150:])
+
+	connectTo(t, server)
+
+	if response := RconExecute("status"); response != status {
+		t.Fatalf("RconExecute(status) = %q, want all parts joined: %q", response, status)
+	}
+
+	// No part of the status is left over for the next command
+	if response := RconExecute("name"); response != rcontest.NameResponse("me") {
+		t.Fatalf("RconExecute(name) after status = %q, want %q", response, rcontest.NameResponse("me"))
+	}
+}
+
+func TestConnectWrongPassword(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+	server.RejectAuth(true)
+
+	Configure(config.Rcon{Host: server.Host(), Port: server.Port(), Password: testPassword, DialTimeout: config.Duration(time.Second)})
+	supervisor = NewSupervisor()
+	defer supervisor.Close()
+
+	retrying := make(chan StatusChange, 1)
+	supervisor.OnStatus(func(change StatusChange) {
+		if change.Status == StatusRetrying {
+			select {
+			case retrying <- change:
+			default:
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := Connect(ctx); err == nil {
+		t.Fatal("Connect() succeeded with a rejected password")
+	}
+
+	select {
+	case change := <-retrying:
+		if change.Attempt != 1 || change.Error == nil {
+			t.Fatalf("retrying status = %+v, want the first attempt with its error", change)
+		}
+	default:
+		t.Fatal("no retrying status after the failed attempt")
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+
+	connectTo(t, server)
+
+	reconnected := make(chan struct{}, 1)
+	supervisor.OnStatus(func(change StatusChange) {
+		if change.Status == StatusConnected {
+			reconnected <- struct{}{}
+		}
+	})
+
+	// The game quits mid-command, the command gets no response
+	server.DropNext(1)
+	if response := RconExecute("echo dropped"); response != "" {
+		t.Fatalf("RconExecute on a dropped connection = %q, want an empty response", response)
+	}
+
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the supervisor did not reconnect")
+	}
+
+	if response := RconExecute("name"); response != rcontest.NameResponse("me") {
+		t.Fatalf("RconExecute(name) after reconnecting = %q", response)
+	}
+}
+
+func TestSayEscapesMessage(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+
+	connectTo(t, server)
+
+	Say("hi \"there\"; quit\n", false, PriorityNormal).Wait()
+	Say("team", true, PriorityNormal).Wait()
+
+	waitForCommand(t, server, `say "hi 'there', quit "`)
+	waitForCommand(t, server, `say_team "team"`)
+}
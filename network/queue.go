@@ -0,0 +1,245 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority orders queued RCON commands, higher priorities run first
+type Priority int
+
+const (
+	// PriorityLow is for chat output that may wait
+	PriorityLow Priority = iota
+	// PriorityNormal is the default for commands whose response is needed
+	PriorityNormal
+	// PriorityHigh is for commands that must not wait behind others
+	PriorityHigh
+)
+
+// dedupedCommands are pure queries, a pending one answers all identical requests
+var dedupedCommands = map[string]bool{
+	"status":         true,
+	"tf_lobby_debug": true,
+	"name":           true,
+}
+
+// Future is the pending response of a queued command
+type Future struct {
+	done     chan struct{}
+	response string
+}
+
+// Wait blocks until the command ran and returns its response
+func (f *Future) Wait() string {
+	<-f.done
+	return f.response
+}
+
+// Done is closed once the command ran
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// queuedCommand is a command waiting for the worker
+type queuedCommand struct {
+	command  string
+	priority Priority
+	seq      uint64
+	futures  []*Future
+}
+
+// Queue runs RCON commands one at a time on a single worker, ordered by priority and then by arrival.
+// Commands with a rate limit are held back until their interval passed, without blocking other commands.
+type Queue struct {
+	execute func(string) string
+
+	mu      sync.Mutex
+	pending []*queuedCommand
+	seq     uint64
+	limits  map[string]time.Duration
+	lastRun map[string]time.Time
+	wakeup  chan struct{}
+	// stopped is set once Run returned, later commands are answered right away with an empty response
+	stopped bool
+}
+
+// NewQueue creates a queue that runs commands with execute, start it with Run
+func NewQueue(execute func(string) string) *Queue {
+	return &Queue{
+		execute: execute,
+		limits:  make(map[string]time.Duration),
+		lastRun: make(map[string]time.Time),
+		wakeup:  make(chan struct{}, 1),
+	}
+}
+
+// SetRateLimit allows at most one command of the given verb (e.g. `say`) per interval, 0 removes the limit
+func (q *Queue) SetRateLimit(verb string, interval time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if interval <= 0 {
+		delete(q.limits, limitKey(verb))
+		return
+	}
+
+	q.limits[limitKey(verb)] = interval
+}
+
+// Enqueue queues the command and returns the future of its response
+func (q *Queue) Enqueue(command string, priority Priority) *Future {
+	future := &Future{done: make(chan struct{})}
+
+	q.mu.Lock()
+
+	if q.stopped {
+		q.mu.Unlock()
+		close(future.done)
+		return future
+	}
+
+	// Redundant queries ride along with the pending one
+	if dedupedCommands[command] {
+		for _, pending := range q.pending {
+			if pending.command == command {
+				pending.futures = append(pending.futures, future)
+				if priority > pending.priority {
+					pending.priority = priority
+				}
+				q.mu.Unlock()
+				return future
+			}
+		}
+	}
+
+	q.seq++
+	q.pending = append(q.pending, &queuedCommand{
+		command:  command,
+		priority: priority,
+		seq:      q.seq,
+		futures:  []*Future{future},
+	})
+	q.mu.Unlock()
+
+	q.notify()
+	return future
+}
+
+// Run executes queued commands until stop is closed, the commands still queued then are answered with an empty response
+func (q *Queue) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			q.abort()
+			return
+		default:
+		}
+
+		next, wait := q.next()
+
+		if next == nil {
+			select {
+			case <-stop:
+				q.abort()
+				return
+			case <-q.wakeup:
+			case <-timerC(wait):
+			}
+			continue
+		}
+
+		response := q.execute(next.command)
+
+		for _, future := range next.futures {
+			future.response = response
+			close(future.done)
+		}
+	}
+}
+
+// next takes the command to run now, if none is ready it returns how long to wait for a rate limit (0 for no limit)
+func (q *Queue) next() (*queuedCommand, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	best := -1
+	var wait time.Duration
+
+	for i, pending := range q.pending {
+		key := limitKey(verbOf(pending.command))
+
+		// Held back by its rate limit, remember when it becomes ready
+		if interval, ok := q.limits[key]; ok {
+			if ready := q.lastRun[key].Add(interval); ready.After(now) {
+				if remaining := ready.Sub(now); wait == 0 || remaining < wait {
+					wait = remaining
+				}
+				continue
+			}
+		}
+
+		if best < 0 || pending.priority > q.pending[best].priority ||
+			(pending.priority == q.pending[best].priority && pending.seq < q.pending[best].seq) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return nil, wait
+	}
+
+	next := q.pending[best]
+	q.pending = append(q.pending[:best], q.pending[best+1:]...)
+	q.lastRun[limitKey(verbOf(next.command))] = now
+
+	return next, 0
+}
+
+// abort answers all queued commands with an empty response and refuses new ones
+func (q *Queue) abort() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	for _, pending := range q.pending {
+		for _, future := range pending.futures {
+			close(future.done)
+		}
+	}
+	q.pending = nil
+}
+
+// notify wakes the worker up without blocking
+func (q *Queue) notify() {
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// timerC returns a channel firing after d, or nil (never firing) for 0
+func timerC(d time.Duration) <-chan time.Time {
+	if d <= 0 {
+		return nil
+	}
+
+	return time.After(d)
+}
+
+// verbOf returns the first word of the command
+func verbOf(command string) string {
+	verb, _, _ := strings.Cut(command, " ")
+	return verb
+}
+
+// limitKey maps a verb to the rate limit it shares, TF2 counts all chat towards the same flood limit
+func limitKey(verb string) string {
+	if verb == "say_team" {
+		return "say"
+	}
+
+	return verb
+}
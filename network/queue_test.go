@@ -0,0 +1,176 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder executes commands for a test queue, it remembers them and holds the first one until release is closed
+type recorder struct {
+	mu       sync.Mutex
+	commands []string
+	times    []time.Time
+	// started is closed once the first command is held
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newRecorder() *recorder {
+	return &recorder{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (r *recorder) execute(command string) string {
+	r.once.Do(func() {
+		close(r.started)
+		<-r.release
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commands = append(r.commands, command)
+	r.times = append(r.times, time.Now())
+	return "response of " + command
+}
+
+func (r *recorder) executed() ([]string, []time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.commands...), append([]time.Time(nil), r.times...)
+}
+
+// startTestQueue runs a queue on the recorder until the test ends
+func startTestQueue(t *testing.T, r *recorder) *Queue {
+	queue := NewQueue(r.execute)
+	stop := make(chan struct{})
+	go queue.Run(stop)
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	return queue
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	r := newRecorder()
+	queue := startTestQueue(t, r)
+
+	// The worker holds the first command, the rest queue up behind it
+	first := queue.Enqueue("first", PriorityLow)
+	<-r.started
+	low := queue.Enqueue("low", PriorityLow)
+	normal := queue.Enqueue("normal", PriorityNormal)
+	high := queue.Enqueue("high", PriorityHigh)
+	normal2 := queue.Enqueue("normal2", PriorityNormal)
+	close(r.release)
+
+	for _, future := range []*Future{first, low, normal, high, normal2} {
+		future.Wait()
+	}
+
+	commands, _ := r.executed()
+	want := []string{"first", "high", "normal", "normal2", "low"}
+	if len(commands) != len(want) {
+		t.Fatalf("executed %q, want %q", commands, want)
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Fatalf("executed %q, want %q", commands, want)
+		}
+	}
+}
+
+func TestQueueDedupe(t *testing.T) {
+	r := newRecorder()
+	queue := startTestQueue(t, r)
+
+	first := queue.Enqueue("first", PriorityLow)
+	<-r.started
+	say := queue.Enqueue("say hi", PriorityNormal)
+	status1 := queue.Enqueue("status", PriorityLow)
+	status2 := queue.Enqueue("status", PriorityHigh)
+	// Commands with side effects are never merged
+	say2 := queue.Enqueue("say hi", PriorityNormal)
+	close(r.release)
+
+	for _, future := range []*Future{first, say, status1, status2, say2} {
+		future.Wait()
+	}
+
+	if status1.Wait() != "response of status" || status2.Wait() != "response of status" {
+		t.Fatalf("status responses = %q, %q, want both answered", status1.Wait(), status2.Wait())
+	}
+
+	commands, _ := r.executed()
+	want := []string{"first", "status", "say hi", "say hi"}
+	if len(commands) != len(want) {
+		t.Fatalf("executed %q, want %q", commands, want)
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Fatalf("executed %q, want %q, the merged status keeps the higher priority", commands, want)
+		}
+	}
+}
+
+func TestQueueChatRateLimit(t *testing.T) {
+	const interval = 100 * time.Millisecond
+
+	r := newRecorder()
+	close(r.release)
+	queue := startTestQueue(t, r)
+	queue.SetRateLimit("say", interval)
+
+	say := queue.Enqueue(`say "one"`, PriorityLow)
+	team := queue.Enqueue(`say_team "two"`, PriorityLow)
+	// Other commands don't wait behind the held back chat
+	status := queue.Enqueue("status", PriorityLow)
+
+	status.Wait()
+	say.Wait()
+	team.Wait()
+
+	commands, times := r.executed()
+	if len(commands) != 3 || commands[0] != `say "one"` || commands[1] != "status" || commands[2] != `say_team "two"` {
+		t.Fatalf("executed %q, want status to run between the chat lines", commands)
+	}
+
+	// say and say_team share the flood limit of the game
+	if gap := times[2].Sub(times[0]); gap < interval {
+		t.Fatalf("say_team ran %s after say, want at least %s", gap, interval)
+	}
+}
+
+func TestQueueStop(t *testing.T) {
+	r := newRecorder()
+	queue := NewQueue(r.execute)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		queue.Run(stop)
+		close(stopped)
+	}()
+
+	first := queue.Enqueue("first", PriorityLow)
+	<-r.started
+	pending := queue.Enqueue("pending", PriorityLow)
+
+	close(stop)
+	close(r.release)
+	<-stopped
+
+	first.Wait()
+	if response := pending.Wait(); response != "" {
+		t.Fatalf("pending command answered %q after stop, want an empty response", response)
+	}
+
+	select {
+	case <-queue.Enqueue("late", PriorityHigh).Done():
+	default:
+		t.Fatal("a command queued after stop was not answered right away")
+	}
+}
@@ -0,0 +1,281 @@
+// Package rcontest provides an in-process Source RCON server with scriptable responses.
+// It lets Connect, RconExecute and the command handlers run end-to-end without TF2.
+package rcontest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gorcon/rcon"
+)
+
+// ResponseFunc builds the response packets for a received command
+type ResponseFunc func(command string) []string
+
+// Server is a Source RCON server listening on a random port of the loopback interface
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu         sync.Mutex
+	password   string
+	rejectAuth bool
+	responses  map[string]ResponseFunc
+	prefixes   map[string]ResponseFunc
+	dropNext   int
+	commands   []string
+	conns      map[net.Conn]struct{}
+	closed     bool
+}
+
+// NewServer starts a server that accepts the given password.
+// It answers `name` for the given player and every other command with an empty response until scripted otherwise.
+func NewServer(password string, player string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("rcontest: failed to listen on a port: %v", err))
+	}
+
+	s := &Server{
+		listener:  listener,
+		password:  password,
+		responses: make(map[string]ResponseFunc),
+		prefixes:  make(map[string]ResponseFunc),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	s.Respond("name", NameResponse(player))
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// NameResponse returns the response of the `name` command for the given player
+func NameResponse(player string) string {
+	return fmt.Sprintf(`"name" = "%s" ( def. "unnamed" )`, player)
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host returns the host the server listens on
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens on
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Respond makes the server answer the exact command with the response in a single packet
+func (s *Server) Respond(command string, response string) {
+	s.RespondMulti(command, response)
+}
+
+// RespondMulti makes the server answer the exact command with one packet per part, like a response too large for a single packet
+func (s *Server) RespondMulti(command string, parts ...string) {
+	s.RespondFunc(command, func(string) []string {
+		return parts
+	})
+}
+
+// RespondFunc makes the server answer the exact command with the packets built by fn
+func (s *Server) RespondFunc(command string, fn ResponseFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[command] = fn
+}
+
+// RespondPrefix makes the server answer every command starting with prefix (e.g. `say `) with the packets built by fn
+func (s *Server) RespondPrefix(prefix string, fn ResponseFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefixes[prefix] = fn
+}
+
+// RejectAuth makes all following authentication attempts fail
+func (s *Server) RejectAuth(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejectAuth = reject
+}
+
+// DropNext makes the server close the connection instead of answering the next n commands
+func (s *Server) DropNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropNext = n
+}
+
+// DropConnections closes all open connections, like a game that quit or changed maps
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Commands returns all commands received so far, in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.commands...)
+}
+
+// Close stops the server and closes all open connections
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	_ = s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+}
+
+// serve accepts connections until the server is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle answers the packets of a single connection until it is closed
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+
+	// Eventually close the connection, ignore error.
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	authenticated := false
+
+	for {
+		request := &rcon.Packet{}
+		if _, err := request.ReadFrom(conn); err != nil {
+			return
+		}
+
+		switch request.Type {
+		case rcon.SERVERDATA_AUTH:
+			authenticated = s.auth(conn, request)
+
+		case rcon.SERVERDATA_EXECCOMMAND:
+			if !authenticated || !s.execute(conn, request) {
+				return
+			}
+
+		case rcon.SERVERDATA_RESPONSE_VALUE:
+			// Mirror the empty packet clients send to detect the end of a multi-packet response
+			write(conn, rcon.SERVERDATA_RESPONSE_VALUE, request.ID, "")
+			write(conn, rcon.SERVERDATA_RESPONSE_VALUE, request.ID, "\x00\x01")
+		}
+	}
+}
+
+// auth answers an authentication request and reports whether it succeeded
+func (s *Server) auth(conn net.Conn, request *rcon.Packet) bool {
+	s.mu.Lock()
+	ok := !s.rejectAuth && request.Body() == s.password
+	s.mu.Unlock()
+
+	// The server first sends an empty SERVERDATA_RESPONSE_VALUE
+	write(conn, rcon.SERVERDATA_RESPONSE_VALUE, request.ID, "")
+
+	// If authentication failed, the ID must be -1
+	if !ok {
+		write(conn, rcon.SERVERDATA_AUTH_RESPONSE, -1, "")
+		return false
+	}
+
+	write(conn, rcon.SERVERDATA_AUTH_RESPONSE, request.ID, "")
+	return true
+}
+
+// execute answers a command and reports whether the connection stays open
+func (s *Server) execute(conn net.Conn, request *rcon.Packet) bool {
+	command := request.Body()
+
+	s.mu.Lock()
+	s.commands = append(s.commands, command)
+
+	if s.dropNext > 0 {
+		s.dropNext--
+		s.mu.Unlock()
+		return false
+	}
+
+	fn := s.lookup(command)
+	s.mu.Unlock()
+
+	parts := []string{""}
+	if fn != nil {
+		parts = fn(command)
+	}
+
+	for _, part := range parts {
+		write(conn, rcon.SERVERDATA_RESPONSE_VALUE, request.ID, part)
+	}
+
+	return true
+}
+
+// lookup finds the response for the command, exact matches win over prefixes.
+// The caller must hold the lock.
+func (s *Server) lookup(command string) ResponseFunc {
+	if fn, ok := s.responses[command]; ok {
+		return fn
+	}
+
+	longest := ""
+	for prefix := range s.prefixes {
+		if strings.HasPrefix(command, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+
+	if longest == "" {
+		return nil
+	}
+
+	return s.prefixes[longest]
+}
+
+// write sends a single packet, errors surface on the next read
+func write(conn net.Conn, packetType int32, id int32, body string) {
+	_, _ = rcon.NewPacket(packetType, id, body).WriteTo(conn)
+}
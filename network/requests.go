@@ -0,0 +1,73 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/algo7/tf2_rcon_misc/protocol"
+	"sort"
+	"sync"
+)
+
+// RequestHandler answers a request of a UI-Client, the returned value is sent back as payload of the response
+type RequestHandler func(payload json.RawMessage) (interface{}, error)
+
+// ErrUnknownRequest is returned to UI-Clients for request types without handler
+var ErrUnknownRequest = errors.New("unknown request type")
+
+var (
+	requestHandlersMu sync.RWMutex
+	requestHandlers   = make(map[string]RequestHandler)
+)
+
+// HandleRequest registers the handler for all requests of the given type
+func HandleRequest(requestType string, handler RequestHandler) {
+	requestHandlersMu.Lock()
+	defer requestHandlersMu.Unlock()
+
+	requestHandlers[requestType] = handler
+}
+
+// answerRequest runs the handler of the request and sends the response to the client
+func answerRequest(client *Client, msg Message) {
+	requestHandlersMu.RLock()
+	handler, ok := requestHandlers[msg.Type]
+	requestHandlersMu.RUnlock()
+
+	// Responses carry either a payload or an error
+	response := protocol.New(protocol.TypeResponse, nil)
+	response.ID = msg.ID
+	response.Request = msg.Type
+
+	if !ok {
+		response.Type = protocol.TypeError
+		response.Error = fmt.Sprintf("%v: %s", ErrUnknownRequest, msg.Type)
+		send(client, response)
+		return
+	}
+
+	payload, err := handler(msg.Payload)
+	if err != nil {
+		response.Type = protocol.TypeError
+		response.Error = err.Error()
+	} else {
+		response.Payload = payload
+	}
+
+	send(client, response)
+}
+
+// RequestTypes returns the sorted types of all requests the UI-Clients may send
+func RequestTypes() []string {
+	requestHandlersMu.RLock()
+	defer requestHandlersMu.RUnlock()
+
+	// exit is handled by the reader itself
+	types := []string{"exit"}
+	for requestType := range requestHandlers {
+		types = append(types, requestType)
+	}
+
+	sort.Strings(types)
+	return types
+}
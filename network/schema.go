@@ -0,0 +1,53 @@
+package network
+
+//go:generate go run ./schemagen -o schema.json
+
+import (
+	"github.com/algo7/tf2_rcon_misc/protocol"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// MessageSpec describes the payload of an outgoing message type, nil payloads may carry anything
+type MessageSpec struct {
+	Type    string
+	Payload interface{}
+}
+
+// Messages lists all message types sent to the UI-Clients, schema.json is generated from it
+var Messages = []MessageSpec{
+	{Type: protocol.TypeHello, Payload: protocol.HelloPayload{}},
+	{Type: protocol.TypePlayerUpdate, Payload: utils.PlayerUpdate{}},
+	{Type: protocol.TypeFrag, Payload: utils.FragInfo{}},
+	{Type: protocol.TypeSuicide, Payload: utils.SuicideInfo{}},
+	{Type: protocol.TypeServerInfo, Payload: utils.ServerSession{}},
+	{Type: protocol.TypeRconStatus, Payload: utils.RconStatusInfo{}},
+	{Type: protocol.TypeDetection, Payload: utils.DetectionInfo{}},
+	{Type: protocol.TypeApplicationLog, Payload: protocol.LogPayload{}},
+	{Type: protocol.TypeResponse},
+	{Type: protocol.TypeError},
+	{Type: protocol.TypeShutdown, Payload: protocol.ShutdownPayload{}},
+}
+
+// ResponseSpec describes the payload of the response to a request type
+type ResponseSpec struct {
+	Request string
+	Payload interface{}
+}
+
+// Responses lists the request types answered with a payload, the others are answered without one
+var Responses = []ResponseSpec{
+	{Request: "get-players", Payload: utils.PlayerUpdate{}},
+	// The mark is null once it was removed
+	{Request: "mark-player", Payload: &utils.PlayerMark{}},
+	{Request: "history", Payload: utils.HistoryPayload{}},
+}
+
+// MessageTypes returns the types of all messages sent to the UI-Clients
+func MessageTypes() []string {
+	types := make([]string, 0, len(Messages))
+	for _, spec := range Messages {
+		types = append(types, spec.Type)
+	}
+
+	return types
+}
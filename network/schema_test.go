@@ -0,0 +1,320 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/algo7/tf2_rcon_misc/protocol"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// capture is a Sender that keeps the sent messages
+type capture struct {
+	messages [][]byte
+}
+
+func (c *capture) Send(data []byte) {
+	c.messages = append(c.messages, data)
+}
+
+// samplePlayers returns a player with everything we know about them and a freshly joined one
+func samplePlayers() []*utils.PlayerInfo {
+	return []*utils.PlayerInfo{
+		{
+			SteamID: 76561198012345678, Name: "known", UserID: 2, SteamAccType: "U", SteamUniverse: 1,
+			Connected: "12:34", Ping: 50, State: "active", LastSeen: 1700000000,
+			Team: "TF_GC_TEAM_DEFENDERS", MemberType: "MEMBER", Type: "MATCH_PLAYER", IsMe: true,
+			Mark:       &utils.PlayerMark{Attributes: []string{utils.MarkCheater}, Notes: "aimbot", MarkedAt: 1690000000},
+			Encounters: &utils.Encounters{Sessions: 3, Minutes: 90, Servers: 2, FirstSeen: 1680000000, LastSeen: 1690000000},
+		},
+		{SteamID: 76561198087654321, Name: "new", UserID: 3, SteamAccType: "U", SteamUniverse: 1, Connected: "00:05", State: "spawning", LastSeen: 1700000000},
+	}
+}
+
+// sampleHistory returns a history response with one of each record
+func sampleHistory() utils.HistoryPayload {
+	return utils.HistoryPayload{
+		Chats: []utils.ChatRecord{{SteamID: 76561198012345678, Name: "known", Message: "gg", SessionID: "abc", UpdatedAt: 1700000000}},
+		Frags: []utils.FragRecord{{KillerSteamID: 76561198012345678, VictimSteamID: 76561198087654321, KillerName: "known", VictimName: "new", Weapon: "scattergun", Crit: true, Map: "pl_upward", SessionID: "abc", UpdatedAt: 1700000000}},
+		Names: []utils.NameRecord{{SteamID: 76561198012345678, Name: "known", FirstSeen: 1680000000, LastSeen: 1700000000, Server: "1.2.3.4:27015", Hostname: "Uncletopia"}},
+	}
+}
+
+// sendSamples sends a message of every type in Messages with a realistic payload, like the program does
+func sendSamples(t *testing.T) map[string][][]byte {
+	t.Helper()
+
+	HandleRequest("get-players", func(json.RawMessage) (interface{}, error) {
+		return utils.PlayerUpdate{CurrentPlayers: samplePlayers()}, nil
+	})
+	HandleRequest("history", func(json.RawMessage) (interface{}, error) {
+		return sampleHistory(), nil
+	})
+	HandleRequest("mark-player", func(payload json.RawMessage) (interface{}, error) {
+		// An empty request removes the mark, the response is null then
+		if string(payload) == "{}" {
+			var removed *utils.PlayerMark
+			return removed, nil
+		}
+		return &utils.PlayerMark{Attributes: []string{utils.MarkBot}, MarkedAt: 1700000000}, nil
+	})
+	HandleRequest("say", func(json.RawMessage) (interface{}, error) {
+		return nil, nil
+	})
+	HandleRequest("vote-kick", func(json.RawMessage) (interface{}, error) {
+		return nil, errors.New("vote failed")
+	})
+
+	sent := &capture{}
+
+	SendHello(sent)
+	SendPlayers(sent, samplePlayers())
+	SendFrag(sent, &utils.FragInfo{KillerName: "known", VictimName: "new", KillerSteamID: "76561198012345678", VictimSteamID: "76561198087654321", Weapon: "scattergun", Crit: true})
+	SendSuicide(sent, &utils.SuicideInfo{PlayerName: "new", SteamID: "76561198087654321"})
+	SendServerInfo(sent, &utils.ServerSession{ID: "abc", Address: "1.2.3.4:27015", Hostname: "Uncletopia", Map: "pl_upward", Tags: []string{"payload"}, SteamID: 85568392924469990, StartedAt: 1700000000, EndedAt: 1700003600})
+	SendRconStatus(sent, &utils.RconStatusInfo{Status: string(StatusRetrying), Attempt: 2, RetryIn: 2000, Error: "connection refused"})
+	SendDetection(sent, &utils.DetectionInfo{Rule: "bot names", Kind: "name", SteamID: 76561198087654321, Name: "new", Evidence: "new", DetectedAt: 1700000000})
+	send(sent, protocol.New(protocol.TypeApplicationLog, protocol.LogPayload{Message: "hello"}))
+	send(sent, protocol.New(protocol.TypeShutdown, protocol.ShutdownPayload{Reason: "shutting down"}))
+
+	// Responses and errors go through the request handling of a client
+	client := &Client{send: make(chan []byte, clientQueueSize)}
+	requests := []Message{
+		{Type: "get-players", ID: "1"},
+		{Type: "history", ID: "2", Payload: json.RawMessage(`{"steamID": "76561198012345678"}`)},
+		{Type: "mark-player", ID: "3", Payload: json.RawMessage(`{"steamID": "76561198012345678", "attributes": ["bot"]}`)},
+		{Type: "mark-player", ID: "4", Payload: json.RawMessage(`{}`)},
+		{Type: "say", ID: "5", Payload: json.RawMessage(`{"message": "gg"}`)},
+		{Type: "vote-kick", ID: "6", Payload: json.RawMessage(`{"steamID": "76561198087654321"}`)},
+		{Type: "no-such-request", ID: "7"},
+	}
+	for _, request := range requests {
+		answerRequest(client, request)
+		sent.Send(<-client.send)
+	}
+
+	byType := make(map[string][][]byte)
+	for _, message := range sent.messages {
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(message, &envelope); err != nil {
+			t.Fatalf("sent invalid JSON %s: %v", message, err)
+		}
+		byType[envelope.Type] = append(byType[envelope.Type], message)
+	}
+
+	return byType
+}
+
+func TestMessagesMatchSchema(t *testing.T) {
+	schema := loadSchema(t)
+	byType := sendSamples(t)
+
+	for _, spec := range Messages {
+		messages := byType[spec.Type]
+		if len(messages) == 0 {
+			t.Errorf("no sample of %s, add one to sendSamples", spec.Type)
+			continue
+		}
+
+		for _, message := range messages {
+			if err := validate(schema, decode(t, message), ""); err != nil {
+				t.Errorf("%s does not match the schema: %v\n%s", spec.Type, err, message)
+			}
+		}
+	}
+}
+
+func TestSchemaRejectsInvalidPayloads(t *testing.T) {
+	schema := loadSchema(t)
+
+	invalid := map[string]string{
+		"numeric steamID":      `{"version": 1, "type": "suicide", "ts": 1, "payload": {"PlayerName": "a", "SteamID": 76561198087654321}}`,
+		"unknown type":         `{"version": 1, "type": "nope", "ts": 1}`,
+		"missing payload":      `{"version": 1, "type": "frag", "ts": 1}`,
+		"unknown field":        `{"version": 1, "type": "application-log", "ts": 1, "payload": {"message": "a", "level": "info"}}`,
+		"numeric history id":   `{"version": 1, "type": "response", "ts": 1, "id": "1", "request": "history", "payload": {"chats": [{"SteamID": 1, "Name": "a", "Message": "b", "SessionID": "c", "UpdatedAt": 1}], "frags": [], "names": []}}`,
+		"error without reason": `{"version": 1, "type": "error", "ts": 1, "id": "1", "request": "say"}`,
+	}
+
+	for name, message := range invalid {
+		if err := validate(schema, decode(t, []byte(message)), ""); err == nil {
+			t.Errorf("%s: the schema accepts %s", name, message)
+		}
+	}
+}
+
+// loadSchema reads the generated schema.json
+func loadSchema(t *testing.T) interface{} {
+	t.Helper()
+
+	data, err := os.ReadFile("schema.json")
+	if err != nil {
+		t.Fatalf("Unable to read the schema: %v", err)
+	}
+
+	return decode(t, data)
+}
+
+// decode unmarshals JSON keeping numbers as json.Number, so integers can be told apart
+func decode(t *testing.T, data []byte) interface{} {
+	t.Helper()
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("Unable to decode %s: %v", data, err)
+	}
+
+	return value
+}
+
+// validate checks the value against the schema, it knows the keywords schemagen generates
+func validate(schema interface{}, value interface{}, path string) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if types, ok := s["type"]; ok {
+		if err := validateType(types, value, path); err != nil {
+			return err
+		}
+	}
+
+	if constant, ok := s["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: %v is not %v", path, value, constant)
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || reflect.DeepEqual(allowed, value)
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is none of %v", path, value, enum)
+		}
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		if str, isString := value.(string); isString && !regexp.MustCompile(pattern).MatchString(str) {
+			return fmt.Errorf("%s: %q does not match %s", path, str, pattern)
+		}
+	}
+
+	if object, isObject := value.(map[string]interface{}); isObject {
+		if err := validateObject(s, object, path); err != nil {
+			return err
+		}
+	}
+
+	if array, isArray := value.([]interface{}); isArray {
+		for i, item := range array {
+			if err := validate(s["items"], item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := validate(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if condition, ok := s["if"]; ok && validate(condition, value, path) == nil {
+		if err := validate(s["then"], value, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateObject checks the required, known and additional properties of an object
+func validateObject(s map[string]interface{}, object map[string]interface{}, path string) error {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, present := object[name.(string)]; !present {
+				return fmt.Errorf("%s: %s is missing", path, name)
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	for name, property := range object {
+		if sub, known := properties[name]; known {
+			if err := validate(sub, property, path+"."+name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unknown property %s", path, name)
+			}
+		case map[string]interface{}:
+			if err := validate(additional, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateType checks the value against a single type or a list of types
+func validateType(types interface{}, value interface{}, path string) error {
+	var allowed []string
+	switch t := types.(type) {
+	case string:
+		allowed = []string{t}
+	case []interface{}:
+		for _, name := range t {
+			allowed = append(allowed, name.(string))
+		}
+	}
+
+	for _, name := range allowed {
+		if hasType(name, value) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %v is not of type %s", path, value, strings.Join(allowed, " or "))
+}
+
+// hasType reports whether the decoded value is of the JSON Schema type
+func hasType(name string, value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case json.Number:
+		_, err := v.Int64()
+		return name == "number" || (name == "integer" && err == nil)
+	case []interface{}:
+		return name == "array"
+	case map[string]interface{}:
+		return name == "object"
+	}
+
+	return false
+}
@@ -0,0 +1,188 @@
+// Command schemagen generates the JSON Schema of all websocket messages sent to the UI-Clients.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/protocol"
+)
+
+// schema is a JSON Schema node, encoding/json sorts the keys which keeps the output stable
+type schema map[string]interface{}
+
+func main() {
+	output := flag.String("o", "schema.json", "file the schema is written to, - for stdout")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(generate(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to marshal the schema: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if *output == "-" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write the schema: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate builds the schema of the envelope, the payload is selected by the message type
+func generate() schema {
+	conditions := make([]interface{}, 0, len(network.Messages))
+	for _, spec := range network.Messages {
+		then := schema{}
+
+		switch {
+		case spec.Type == protocol.TypeError:
+			then["required"] = []string{"id", "request", "error"}
+		case spec.Type == protocol.TypeResponse:
+			then["required"] = []string{"id", "request"}
+		case spec.Payload != nil:
+			then["required"] = []string{"payload"}
+			then["properties"] = schema{"payload": typeSchema(reflect.TypeOf(spec.Payload))}
+		}
+
+		conditions = append(conditions, schema{
+			"if":   schema{"properties": schema{"type": schema{"const": spec.Type}}},
+			"then": then,
+		})
+	}
+
+	// The payload of a response depends on the request it answers
+	for _, spec := range network.Responses {
+		conditions = append(conditions, schema{
+			"if": schema{
+				"required": []string{"request"},
+				"properties": schema{
+					"type":    schema{"const": protocol.TypeResponse},
+					"request": schema{"const": spec.Request},
+				},
+			},
+			"then": schema{"properties": schema{"payload": typeSchema(reflect.TypeOf(spec.Payload))}},
+		})
+	}
+
+	return schema{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "TF2-RCON-MISC websocket messages",
+		"description":          "Generated by network/schemagen, do not edit.",
+		"type":                 "object",
+		"required":             []string{"version", "type", "ts"},
+		"additionalProperties": false,
+		"properties": schema{
+			"version": schema{"const": protocol.Version},
+			"type":    schema{"enum": network.MessageTypes()},
+			"ts":      schema{"type": "integer", "description": "unix time in milliseconds"},
+			"id":      schema{"type": "string"},
+			"request": schema{"type": "string"},
+			"error":   schema{"type": "string"},
+			"payload": schema{},
+		},
+		"allOf": conditions,
+	}
+}
+
+// typeSchema describes how encoding/json marshals values of the given type
+func typeSchema(t reflect.Type) schema {
+	// nil pointers are marshalled as null
+	if t.Kind() == reflect.Ptr {
+		return nullable(typeSchema(t.Elem()))
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Slice, reflect.Array:
+		// nil slices are marshalled as null
+		return schema{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return schema{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	default:
+		return schema{}
+	}
+}
+
+// structSchema describes the exported fields of a struct by their JSON names
+func structSchema(t reflect.Type) schema {
+	properties := schema{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		var options []string
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			options = parts[1:]
+		}
+
+		property := typeSchema(field.Type)
+		if hasOption(options, "string") && property["type"] == "integer" {
+			// Large numbers like steamID64 are quoted to survive JavaScript
+			property = schema{"type": "string", "pattern": "^-?[0-9]+$"}
+		}
+
+		properties[name] = property
+		if !hasOption(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return schema{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// nullable allows null in addition to the type of the schema, slices and maps allow it already
+func nullable(s schema) schema {
+	if single, ok := s["type"].(string); ok {
+		s["type"] = []string{single, "null"}
+	}
+
+	return s
+}
+
+// hasOption reports whether the json tag options contain the given one
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,271 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Status is the state of the RCON connection
+type Status string
+
+const (
+	// StatusConnected means commands are sent to the game
+	StatusConnected Status = "connected"
+	// StatusDisconnected means the connection was lost, a reconnect follows
+	StatusDisconnected Status = "disconnected"
+	// StatusRetrying means a reconnect attempt failed and the next one is scheduled
+	StatusRetrying Status = "retrying"
+)
+
+// StatusChange describes a change of the RCON connection status
+type StatusChange struct {
+	Status Status
+	// Attempt counts the failed reconnect attempts, 0 unless retrying
+	Attempt int
+	// Retry is the pause before the next attempt, 0 unless retrying
+	Retry time.Duration
+	// Error is the reason for a disconnect or failed attempt
+	Error error
+}
+
+// ErrDisconnected is returned for commands while there is no RCON connection
+var ErrDisconnected = errors.New("rcon: not connected")
+
+const (
+	// minBackoff is the pause after the first failed reconnect attempt
+	minBackoff = 1 * time.Second
+	// maxBackoff caps the exponential backoff between reconnect attempts
+	maxBackoff = 1 * time.Minute
+)
+
+// Supervisor owns the RCON connection, on failure it reconnects in the background with exponential backoff and jitter, forever
+type Supervisor struct {
+	mu           sync.Mutex
+	conn         *rconConn
+	status       StatusChange
+	reconnecting bool
+	// closed stops all reconnects, see Close
+	closed bool
+	// stop is closed by Close, it wakes up a reconnect loop waiting for its next attempt
+	stop      chan struct{}
+	ctx       context.Context
+	connected chan struct{}
+	listeners []func(StatusChange)
+}
+
+// NewSupervisor creates a supervisor without a connection, start it with Connect
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		status:    StatusChange{Status: StatusDisconnected},
+		stop:      make(chan struct{}),
+		ctx:       context.Background(),
+		connected: make(chan struct{}),
+	}
+}
+
+// OnStatus registers a listener for all following status changes
+func (s *Supervisor) OnStatus(listener func(StatusChange)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// Connect starts connecting and blocks until the first connection is established, the context is cancelled
+// or the supervisor is closed. Once the context is cancelled no more reconnects are attempted.
+func (s *Supervisor) Connect(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	connected := s.connected
+	s.mu.Unlock()
+
+	s.reconnect()
+
+	select {
+	case <-connected:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.stop:
+		return ErrDisconnected
+	}
+}
+
+// Execute executes the command on the current connection, a failed connection is replaced in the background
+func (s *Supervisor) Execute(command string) (string, error) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return "", ErrDisconnected
+	}
+
+	response, err := conn.Execute(command)
+	if err == nil {
+		return response, nil
+	}
+
+	// Ignore protocol errors on tf_lobby_debug and status, they seem to happen almost always
+	if (command == "tf_lobby_debug" || command == "status") && !isConnectionError(err) {
+		return response, err
+	}
+
+	log.Printf("Unable to execute the command: %s because %v", command, err)
+	s.drop(conn, err)
+
+	return response, err
+}
+
+// drop discards the failed connection and starts reconnecting
+func (s *Supervisor) drop(conn *rconConn, reason error) {
+	s.mu.Lock()
+
+	// Another command may have dropped it already
+	if s.conn != conn {
+		s.mu.Unlock()
+		return
+	}
+
+	_ = conn.Close()
+	s.conn = nil
+	s.connected = make(chan struct{})
+	s.mu.Unlock()
+
+	log.Println("Connection failed, reconnecting...")
+	s.notify(StatusChange{Status: StatusDisconnected, Error: reason})
+	s.reconnect()
+}
+
+// Close closes the current connection and stops reconnecting for good
+func (s *Supervisor) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// reconnect starts the reconnect loop unless it is already running
+func (s *Supervisor) reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reconnecting || s.conn != nil || s.closed {
+		return
+	}
+
+	s.reconnecting = true
+	go s.reconnectLoop(s.ctx)
+}
+
+// reconnectLoop tries to connect until it succeeds, the supervisor is closed or the context is cancelled
+func (s *Supervisor) reconnectLoop(ctx context.Context) {
+	for attempt := 1; ; attempt++ {
+		conn, err := s.dial()
+
+		if err == nil {
+			s.mu.Lock()
+
+			// Closed while dialing, nobody wants the connection anymore
+			if s.closed || ctx.Err() != nil {
+				s.reconnecting = false
+				s.mu.Unlock()
+				_ = conn.Close()
+				return
+			}
+
+			s.conn = conn
+			s.reconnecting = false
+			close(s.connected)
+			s.mu.Unlock()
+
+			s.notify(StatusChange{Status: StatusConnected})
+			return
+		}
+
+		retry := backoff(attempt)
+		log.Printf("Rcon connection failed (%v), retrying in %s, attempt %d...\n", err, retry.Round(time.Millisecond), attempt)
+		s.notify(StatusChange{Status: StatusRetrying, Attempt: attempt, Retry: retry, Error: err})
+
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+		case <-s.stop:
+		}
+
+		// Stopped while waiting for the next attempt
+		s.mu.Lock()
+		if s.closed || ctx.Err() != nil {
+			s.reconnecting = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// dial resolves the host and connects to it.
+// Without a configured host the LAN is scanned again every time, the game may have come back on another address after a restart.
+func (s *Supervisor) dial() (*rconConn, error) {
+	host := rconConfig.Host
+	if host == "" {
+		host = determineRconHost()
+	}
+
+	if host == "" {
+		return nil, errors.New("rcon host detection failed")
+	}
+
+	return rconConnect(host)
+}
+
+// Status returns the last status change
+func (s *Supervisor) Status() StatusChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// notify records the status change and calls all status listeners
+func (s *Supervisor) notify(change StatusChange) {
+	s.mu.Lock()
+	s.status = change
+	listeners := append([]func(StatusChange){}, s.listeners...)
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// backoff returns the pause before the given attempt, doubling from minBackoff up to maxBackoff with ±50% jitter
+func backoff(attempt int) time.Duration {
+	pause := maxBackoff
+	if attempt < 32 {
+		if doubled := minBackoff << (attempt - 1); doubled < maxBackoff {
+			pause = doubled
+		}
+	}
+
+	return pause/2 + time.Duration(rand.Int63n(int64(pause)))
+}
+
+// isConnectionError reports whether the error means the connection itself is broken
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.As(err, &netErr)
+}
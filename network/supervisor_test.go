@@ -0,0 +1,148 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/config"
+	"github.com/algo7/tf2_rcon_misc/network/rcontest"
+)
+
+func TestBackoffRange(t *testing.T) {
+	for attempt := 1; attempt <= 40; attempt++ {
+		pause := maxBackoff
+		if attempt <= 6 {
+			pause = minBackoff << (attempt - 1)
+		}
+
+		// ±50% jitter around the doubled pause
+		for i := 0; i < 100; i++ {
+			if got := backoff(attempt); got < pause/2 || got >= pause/2+pause {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s)", attempt, got, pause/2, pause/2+pause)
+			}
+		}
+	}
+}
+
+// newTestSupervisor points the RCON settings at the server and returns a supervisor that is closed when the test ends
+func newTestSupervisor(t *testing.T, server *rcontest.Server) *Supervisor {
+	Configure(config.Rcon{Host: server.Host(), Port: server.Port(), Password: testPassword, DialTimeout: config.Duration(time.Second)})
+
+	s := NewSupervisor()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// statusesOf sends every status change of the supervisor to the returned channel
+func statusesOf(s *Supervisor) <-chan StatusChange {
+	statuses := make(chan StatusChange, 16)
+	s.OnStatus(func(change StatusChange) {
+		statuses <- change
+	})
+
+	return statuses
+}
+
+// nextStatus returns the next status change, failing the test if none comes in time
+func nextStatus(t *testing.T, statuses <-chan StatusChange) StatusChange {
+	t.Helper()
+
+	select {
+	case change := <-statuses:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status change in time")
+		return StatusChange{}
+	}
+}
+
+func TestSupervisorReconnectsAfterDrop(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+
+	s := newTestSupervisor(t, server)
+	statuses := statusesOf(s)
+
+	if err := s.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() = %v", err)
+	}
+	if change := nextStatus(t, statuses); change.Status != StatusConnected {
+		t.Fatalf("status = %+v, want connected", change)
+	}
+
+	// The game restarted, the next command finds the connection closed
+	server.DropConnections()
+	if _, err := s.Execute("name"); err == nil {
+		t.Fatal("Execute() on a dropped connection succeeded")
+	}
+
+	if change := nextStatus(t, statuses); change.Status != StatusDisconnected || change.Error == nil {
+		t.Fatalf("status = %+v, want disconnected with the error", change)
+	}
+	if change := nextStatus(t, statuses); change.Status != StatusConnected {
+		t.Fatalf("status = %+v, want connected again", change)
+	}
+
+	response, err := s.Execute("name")
+	if err != nil || response != rcontest.NameResponse("me") {
+		t.Fatalf("Execute(name) after reconnecting = %q, %v", response, err)
+	}
+}
+
+func TestSupervisorCloseStopsReconnecting(t *testing.T) {
+	server := rcontest.NewServer(testPassword, "me")
+	defer server.Close()
+	server.RejectAuth(true)
+
+	s := newTestSupervisor(t, server)
+	statuses := statusesOf(s)
+
+	connected := make(chan error, 1)
+	go func() {
+		connected <- s.Connect(context.Background())
+	}()
+
+	if change := nextStatus(t, statuses); change.Status != StatusRetrying {
+		t.Fatalf("status = %+v, want retrying", change)
+	}
+
+	// Closing wakes the loop up from its backoff, the blocked Connect gives up
+	s.Close()
+
+	select {
+	case err := <-connected:
+		if !errors.Is(err, ErrDisconnected) {
+			t.Fatalf("Connect() = %v, want ErrDisconnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Connect() still blocks after Close")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		reconnecting := s.reconnecting
+		s.mu.Unlock()
+
+		if !reconnecting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the reconnect loop still runs after Close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A closed supervisor never connects again, even once the game takes the password
+	server.RejectAuth(false)
+	s.reconnect()
+
+	select {
+	case change := <-statuses:
+		t.Fatalf("status changed to %+v after Close", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
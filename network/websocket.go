@@ -1,11 +1,14 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"github.com/algo7/tf2_rcon_misc/protocol"
 	"github.com/algo7/tf2_rcon_misc/utils"
 	"github.com/gorilla/websocket"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -26,53 +29,80 @@ func StartWebsocket(port int, callback CallbackFunc) {
 	}
 }
 
+// StopWebsocket tells all clients the reason we shut down, closes their connections once the queued messages were written and stops the server.
+// It gives up waiting for slow clients when the context ends.
+func StopWebsocket(ctx context.Context, reason string) {
+	send(Clients, protocol.New(protocol.TypeShutdown, protocol.ShutdownPayload{Reason: reason}))
+
+	// No new clients from here on, the connections of the existing ones are ours to close
+	if HttpServer != nil {
+		if err := HttpServer.Shutdown(ctx); err != nil {
+			log.Printf("Error stopping the HttpServer: %v", err)
+		}
+	}
+
+	Clients.Close(ctx)
+	log.Println("Websocket closed.")
+}
+
+// OnExit registers the function called when a UI-Client asks us to exit
+func OnExit(callback func()) {
+	onExitCallback = callback
+}
+
+// SendHello announces the protocol version and the capabilities to a freshly connected client
+func SendHello(s Sender) {
+	send(s, protocol.New(protocol.TypeHello, protocol.HelloPayload{
+		Version:      protocol.Version,
+		Capabilities: RequestTypes(),
+		Messages:     MessageTypes(),
+	}))
+}
+
 // SendPlayers Send players encapsulated in a player-update over the wire as JSON.
-func SendPlayers(c *websocket.Conn, players []*utils.PlayerInfo) {
+func SendPlayers(s Sender, players []*utils.PlayerInfo) {
 	if len(players) == 0 {
 		// log.Printf("SendPlayers() Player slice is empty, not sending")
 		return
 	}
 
-	playerUpdate := utils.PlayerUpdate{
-		Type:           "player-update",
-		CurrentPlayers: players,
-	}
+	send(s, protocol.New(protocol.TypePlayerUpdate, utils.PlayerUpdate{CurrentPlayers: players}))
+}
 
-	// Convert the player data to a JSON string
-	jsonData, err := json.Marshal(playerUpdate)
-	if err != nil {
-		log.Panicf("ERROR while marshalling players as JSON: %v", err)
-		return
-	}
+// SendFrag, send new frag entries over the network
+func SendFrag(s Sender, frag *utils.FragInfo) {
+	send(s, protocol.New(protocol.TypeFrag, frag))
+}
 
-	// log.Printf("Sending players, json-payload is: %s", string(jsonData))
+// SendSuicide, send new suicide entries over the network
+func SendSuicide(s Sender, suicide *utils.SuicideInfo) {
+	send(s, protocol.New(protocol.TypeSuicide, suicide))
+}
 
-	if err := c.WriteMessage(websocket.TextMessage, jsonData); err != nil {
-		log.Printf("ERROR while sending players as websocket-message: %v", err)
-		return
-	}
+// SendServerInfo, send the current server session over the network
+func SendServerInfo(s Sender, session *utils.ServerSession) {
+	send(s, protocol.New(protocol.TypeServerInfo, session))
 }
 
-// SendFrag, send new frag entries over the network
-func SendFrag(c *websocket.Conn, frag *utils.FragInfo) {
-	fragWsInfo := utils.FragWsInfo{
-		Type: "frag",
-		Frag: frag,
-	}
+// SendRconStatus, send the RCON connection status over the network
+func SendRconStatus(s Sender, status *utils.RconStatusInfo) {
+	send(s, protocol.New(protocol.TypeRconStatus, status))
+}
 
-	// Convert the frag data to a JSON string
-	jsonData, err := json.Marshal(fragWsInfo)
+// SendDetection, send a matched detection rule over the network
+func SendDetection(s Sender, detection *utils.DetectionInfo) {
+	send(s, protocol.New(protocol.TypeDetection, detection))
+}
+
+// send marshals the message as JSON and queues it on the sender
+func send(s Sender, message protocol.Envelope) {
+	jsonData, err := json.Marshal(message)
 	if err != nil {
-		log.Panicf("ERROR while marshalling frag as JSON: %v", err)
+		log.Panicf("ERROR while marshalling %s as JSON: %v", message.Type, err)
 		return
 	}
 
-	//log.Printf("Sending frag, json-payload is: %s", string(jsonData))
-
-	if err := c.WriteMessage(websocket.TextMessage, jsonData); err != nil {
-		log.Printf("ERROR while sending frag as websocket-message: %v", err)
-		return
-	}
+	s.Send(jsonData)
 }
 
 // WebSocket handler
@@ -87,12 +117,16 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("NEW websocket connection from '%s' (requesting: '%s')!", r.RemoteAddr, r.RequestURI)
 
-	// defer Close, ignore error
+	client := Clients.register(conn)
+
+	// defer unregister and Close, ignore error
 	defer func(conn *websocket.Conn) {
+		Clients.unregister(client)
 		_ = conn.Close()
 	}(conn)
 
-	onConnectCallback(conn)
+	SendHello(client)
+	onConnectCallback(client)
 
 	// Handle WebSocket communication here
 	for {
@@ -100,8 +134,8 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			errStr := err.Error()
 
-			// Ignore connection closes
-			if strings.Contains(errStr, "close ") {
+			// Ignore connection closes, also ours when the client was too slow
+			if strings.Contains(errStr, "close ") || errors.Is(err, net.ErrClosed) {
 				log.Printf("CLOSED connection from '%s' was closed", r.RemoteAddr)
 			} else {
 				log.Panicf("ERROR while reading websocket message: %v", err)
@@ -111,17 +145,12 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Process the received message
-		processRawMessage(messageType, p)
-
-		// Example: Echo back the message
-		if err := conn.WriteMessage(messageType, p); err != nil {
-			return
-		}
+		processRawMessage(client, messageType, p)
 	}
 }
 
 // Process incoming websocket message
-func processRawMessage(messageType int, p []byte) {
+func processRawMessage(client *Client, messageType int, p []byte) {
 	switch messageType {
 	case websocket.TextMessage:
 		// Handle text message
@@ -137,7 +166,7 @@ func processRawMessage(messageType int, p []byte) {
 			return
 		}
 
-		processJsonMessage(msg)
+		processJsonMessage(client, msg)
 	case websocket.BinaryMessage:
 		// Handle binary message
 		log.Printf("Received BinaryMessage over websockets.")
@@ -165,9 +194,21 @@ func processRawMessage(messageType int, p []byte) {
 }
 
 // processJsonMessage Process incomming message over websockets that has already been json-decoded into a struct.
-func processJsonMessage(msg Message) {
+func processJsonMessage(client *Client, msg Message) {
 	// Exit message, telling us to shut down.
 	if msg.Type == "exit" {
-		os.Exit(0)
+		log.Printf("Exit requested by '%s'", client.RemoteAddr())
+		if onExitCallback != nil {
+			onExitCallback()
+		}
+		return
+	}
+
+	// Clients without version speak the current one
+	if msg.Version != 0 && msg.Version != protocol.Version {
+		log.Printf("Client speaks protocol version %d, we speak %d", msg.Version, protocol.Version)
 	}
+
+	// Everything else is a request, answer it without blocking the reader as handlers may wait for RCON
+	go answerRequest(client, msg)
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/playerlist"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// runPlayerlist imports or exports the player marks as TF2 Bot Detector playerlist
+func runPlayerlist(args []string) {
+	if len(args) < 1 {
+		log.Fatalf("Usage: playerlist import|export [flags] <playerlist.json>")
+	}
+
+	openConfiguredDatabase()
+	defer func() {
+		_ = db.Close()
+	}()
+
+	switch args[0] {
+	case "import":
+		importPlayerlist(args[1:])
+	case "export":
+		exportPlayerlist(args[1:])
+	default:
+		log.Fatalf("Unknown playerlist command '%s', use import or export", args[0])
+	}
+}
+
+// importPlayerlist merges a playerlist into the marks stored in the database
+func importPlayerlist(args []string) {
+	flags := flag.NewFlagSet("playerlist import", flag.ExitOnError)
+	source := flags.String("source", "", "name noted on new marks, defaults to the title of the list")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: playerlist import [flags] <playerlist.json>")
+	}
+	path := flags.Arg(0)
+
+	file, err := playerlist.Read(path)
+	if err != nil {
+		log.Fatalf("Unable to read the playerlist: %v", err)
+	}
+
+	if *source == "" {
+		*source = file.FileInfo.Title
+	}
+	if *source == "" {
+		*source = filepath.Base(path)
+	}
+
+	marks := loadMarkMap()
+	now := time.Now().Unix()
+	imported := 0
+
+	for _, player := range file.Players {
+		steamID := int64(player.SteamID)
+
+		mark, changed := playerlist.Import(marks[steamID], player, *source, now)
+		if !changed {
+			continue
+		}
+
+		err := db.SetMark(db.Mark{
+			SteamID:    steamID,
+			Attributes: mark.Attributes,
+			Notes:      mark.Notes,
+			MarkedAt:   mark.MarkedAt,
+		})
+		if err != nil {
+			log.Fatalf("Unable to store the mark of %d: %v", steamID, err)
+		}
+
+		marks[steamID] = mark
+		imported++
+	}
+
+	log.Printf("Imported %d of %d players from '%s'", imported, len(file.Players), path)
+}
+
+// exportPlayerlist writes the marks stored in the database as playerlist
+func exportPlayerlist(args []string) {
+	flags := flag.NewFlagSet("playerlist export", flag.ExitOnError)
+	title := flags.String("title", "TF2-RCON-MISC playerlist", "title of the list")
+	author := flags.String("author", "", "author of the list")
+	description := flags.String("description", "", "description of the list")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: playerlist export [flags] <playerlist.json>")
+	}
+	path := flags.Arg(0)
+
+	info := playerlist.FileInfo{Authors: []string{}, Title: *title, Description: *description}
+	if *author != "" {
+		info.Authors = append(info.Authors, *author)
+	}
+
+	file := playerlist.Export(loadMarkMap(), info)
+	if err := playerlist.Write(path, file); err != nil {
+		log.Fatalf("Unable to write the playerlist: %v", err)
+	}
+
+	log.Printf("Exported %d players to '%s'", len(file.Players), path)
+}
+
+// loadMarkMap returns all marks stored in the database by steamID64, it requires the database
+func loadMarkMap() map[int64]*utils.PlayerMark {
+	marks, err := db.FindMarks()
+	if err != nil {
+		log.Fatalf("Unable to load the player marks: %v", err)
+	}
+
+	bySteamID := make(map[int64]*utils.PlayerMark, len(marks))
+	for _, mark := range marks {
+		bySteamID[mark.SteamID] = toPlayerMark(mark)
+	}
+
+	return bySteamID
+}
@@ -0,0 +1,129 @@
+package playerlist
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Import merges an imported entry into the local mark of the player, it returns the new mark and whether it changed.
+// Friends are never overwritten by a shared list, attributes are combined and cheater supersedes suspicious.
+// Local notes are kept, new marks take the proof of the entry as notes.
+func Import(local *utils.PlayerMark, imported Player, source string, now int64) (*utils.PlayerMark, bool) {
+	var attributes []string
+	for _, attribute := range imported.Attributes {
+		// Ignore everything we can't show, e.g. newer attributes of TF2 Bot Detector.
+		// Friend and trusted are ours to give, a shared list must not grant them.
+		if utils.IsMarkAttribute(attribute) && attribute != utils.MarkFriend && attribute != utils.MarkTrusted {
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	if len(attributes) == 0 {
+		return local, false
+	}
+
+	if local == nil {
+		notes := strings.Join(imported.Proof, "\n")
+		if notes == "" {
+			notes = "Imported from " + source
+		}
+
+		return &utils.PlayerMark{Attributes: normalize(attributes), Notes: notes, MarkedAt: now}, true
+	}
+
+	if contains(local.Attributes, utils.MarkFriend) {
+		return local, false
+	}
+
+	merged := normalize(append(append([]string{}, local.Attributes...), attributes...))
+	if equal(merged, normalize(local.Attributes)) {
+		return local, false
+	}
+
+	return &utils.PlayerMark{Attributes: merged, Notes: local.Notes, MarkedAt: now}, true
+}
+
+// Export converts our marks into a playerlist, marks without attributes TF2 Bot Detector knows are left out
+func Export(marks map[int64]*utils.PlayerMark, info FileInfo) *File {
+	file := &File{Schema: SchemaURL, FileInfo: info, Players: []Player{}}
+
+	for steamID, mark := range marks {
+		var attributes []string
+		for _, attribute := range mark.Attributes {
+			switch attribute {
+			case utils.MarkCheater, utils.MarkBot:
+				// TF2 Bot Detector lists bots as cheaters
+				attributes = append(attributes, AttributeCheater)
+			case utils.MarkSuspicious, utils.MarkExploiter, utils.MarkRacist:
+				attributes = append(attributes, attribute)
+			}
+		}
+
+		if len(attributes) == 0 {
+			continue
+		}
+
+		player := Player{
+			SteamID:    SteamID(steamID),
+			Attributes: normalize(attributes),
+			LastSeen:   &LastSeen{Time: mark.MarkedAt},
+		}
+
+		if mark.Notes != "" {
+			player.Proof = strings.Split(mark.Notes, "\n")
+		}
+
+		file.Players = append(file.Players, player)
+	}
+
+	// Stable output keeps shared lists diffable
+	sort.Slice(file.Players, func(i, j int) bool {
+		return file.Players[i].SteamID < file.Players[j].SteamID
+	})
+
+	return file
+}
+
+// normalize sorts the attributes, removes duplicates and drops suspicious next to cheater
+func normalize(attributes []string) []string {
+	cheater := contains(attributes, utils.MarkCheater)
+
+	var result []string
+	for _, attribute := range attributes {
+		if contains(result, attribute) || (cheater && attribute == utils.MarkSuspicious) {
+			continue
+		}
+		result = append(result, attribute)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// contains reports whether the attribute is in the list
+func contains(attributes []string, attribute string) bool {
+	for _, a := range attributes {
+		if a == attribute {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equal reports whether both lists hold the same attributes in the same order
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package playerlist
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+func TestImport(t *testing.T) {
+	cases := []struct {
+		name     string
+		local    *utils.PlayerMark
+		imported []string
+		want     []string
+		changed  bool
+	}{
+		{"new mark", nil, []string{"cheater"}, []string{"cheater"}, true},
+		{"unknown attributes", nil, []string{"bot-operator"}, nil, false},
+		{"friend is never overwritten", &utils.PlayerMark{Attributes: []string{"friend"}}, []string{"cheater"}, []string{"friend"}, false},
+		{"friend is never granted", nil, []string{"friend"}, nil, false},
+		{"trusted is never granted", &utils.PlayerMark{Attributes: []string{"racist"}}, []string{"trusted"}, []string{"racist"}, false},
+		{"attributes are combined", &utils.PlayerMark{Attributes: []string{"racist"}}, []string{"exploiter"}, []string{"exploiter", "racist"}, true},
+		{"cheater supersedes suspicious", &utils.PlayerMark{Attributes: []string{"suspicious"}}, []string{"cheater"}, []string{"cheater"}, true},
+		{"suspicious does not downgrade cheater", &utils.PlayerMark{Attributes: []string{"cheater"}}, []string{"suspicious"}, []string{"cheater"}, false},
+		{"nothing new", &utils.PlayerMark{Attributes: []string{"cheater", "racist"}}, []string{"racist"}, []string{"cheater", "racist"}, false},
+	}
+
+	for _, c := range cases {
+		mark, changed := Import(c.local, Player{Attributes: c.imported}, "test.json", 100)
+
+		var got []string
+		if mark != nil {
+			got = mark.Attributes
+		}
+
+		if changed != c.changed || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Import() = %v, %v, want %v, %v", c.name, got, changed, c.want, c.changed)
+		}
+	}
+}
+
+func TestImportNotes(t *testing.T) {
+	mark, _ := Import(nil, Player{Attributes: []string{"cheater"}, Proof: []string{"aimbot", "demo"}}, "test.json", 100)
+	if mark.Notes != "aimbot\ndemo" || mark.MarkedAt != 100 {
+		t.Errorf("Import() = %+v, want the proof as notes", mark)
+	}
+
+	mark, _ = Import(nil, Player{Attributes: []string{"cheater"}}, "test.json", 100)
+	if mark.Notes != "Imported from test.json" {
+		t.Errorf("Import() without proof has the notes %q", mark.Notes)
+	}
+
+	local := &utils.PlayerMark{Attributes: []string{"racist"}, Notes: "mine"}
+	if mark, _ = Import(local, Player{Attributes: []string{"cheater"}, Proof: []string{"aimbot"}}, "test.json", 100); mark.Notes != "mine" {
+		t.Errorf("Import() replaced the local notes with %q", mark.Notes)
+	}
+}
+
+func TestExport(t *testing.T) {
+	marks := map[int64]*utils.PlayerMark{
+		utils.Steam3IDToSteam64(3): {Attributes: []string{"bot"}, Notes: "cathook\nname", MarkedAt: 100},
+		utils.Steam3IDToSteam64(1): {Attributes: []string{"cheater", "suspicious", "racist"}},
+		utils.Steam3IDToSteam64(2): {Attributes: []string{"friend", "trusted"}},
+	}
+
+	file := Export(marks, FileInfo{Title: "test"})
+
+	want := []Player{
+		{SteamID: SteamID(utils.Steam3IDToSteam64(1)), Attributes: []string{"cheater", "racist"}, LastSeen: &LastSeen{}},
+		{SteamID: SteamID(utils.Steam3IDToSteam64(3)), Attributes: []string{"cheater"}, LastSeen: &LastSeen{Time: 100}, Proof: []string{"cathook", "name"}},
+	}
+
+	if !reflect.DeepEqual(file.Players, want) {
+		t.Errorf("Export() = %+v, want %+v", file.Players, want)
+	}
+
+	if file.Schema != SchemaURL || file.FileInfo.Title != "test" {
+		t.Errorf("Export() has the schema %q and the info %+v", file.Schema, file.FileInfo)
+	}
+}
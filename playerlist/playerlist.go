@@ -0,0 +1,124 @@
+// Package playerlist reads and writes playerlists in the format of TF2 Bot Detector, see
+// https://github.com/PazerOP/tf2_bot_detector/blob/master/schemas/v3/playerlist.schema.json
+package playerlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// SchemaURL is written to exported playerlists so TF2 Bot Detector accepts them
+const SchemaURL = "https://raw.githubusercontent.com/PazerOP/tf2_bot_detector/master/schemas/v3/playerlist.schema.json"
+
+// Attributes known to TF2 Bot Detector
+const (
+	AttributeCheater    = "cheater"
+	AttributeSuspicious = "suspicious"
+	AttributeExploiter  = "exploiter"
+	AttributeRacist     = "racist"
+)
+
+// File is a playerlist.json
+type File struct {
+	Schema   string   `json:"$schema,omitempty"`
+	FileInfo FileInfo `json:"file_info"`
+	Players  []Player `json:"players"`
+}
+
+// FileInfo describes who maintains the playerlist
+type FileInfo struct {
+	Authors     []string `json:"authors"`
+	Description string   `json:"description,omitempty"`
+	Title       string   `json:"title"`
+	UpdateURL   string   `json:"update_url,omitempty"`
+}
+
+// Player is a single entry of the playerlist
+type Player struct {
+	SteamID    SteamID   `json:"steamid"`
+	Attributes []string  `json:"attributes"`
+	LastSeen   *LastSeen `json:"last_seen,omitempty"`
+	Proof      []string  `json:"proof,omitempty"`
+}
+
+// LastSeen records when and under which name the player was last seen, Time is a unix timestamp
+type LastSeen struct {
+	PlayerName string `json:"player_name,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+// SteamID is a steamID64, playerlists carry it as steamID3 string, steamID64 string or number
+type SteamID int64
+
+// MarshalJSON writes the steamID3 like TF2 Bot Detector does
+func (id SteamID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("[U:1:%d]", utils.Steam64ToSteam3ID(int64(id))))
+}
+
+// UnmarshalJSON reads all notations of steamIDs used in playerlists
+func (id *SteamID) UnmarshalJSON(data []byte) error {
+	var number int64
+	if err := json.Unmarshal(data, &number); err == nil {
+		*id = SteamID(number)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("steamid must be a string or a number: %w", err)
+	}
+
+	// [U:1:<account id>]
+	if strings.HasPrefix(text, "[U:1:") && strings.HasSuffix(text, "]") {
+		accountID, err := strconv.ParseInt(text[len("[U:1:"):len(text)-1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid steamid %q: %w", text, err)
+		}
+
+		*id = SteamID(utils.Steam3IDToSteam64(accountID))
+		return nil
+	}
+
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid steamid %q: %w", text, err)
+	}
+
+	*id = SteamID(number)
+	return nil
+}
+
+// Read parses the playerlist at the given path
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse playerlist %s: %w", path, err)
+	}
+
+	if file.Players == nil {
+		return nil, errors.New("playerlist has no players")
+	}
+
+	return &file, nil
+}
+
+// Write stores the playerlist at the given path
+func Write(path string, file *File) error {
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,87 @@
+package playerlist
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSteamIDNotations(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		want SteamID
+	}{
+		"steamID3":        {`"[U:1:1]"`, 76561197960265729},
+		"steamID64":       {`"76561197960265729"`, 76561197960265729},
+		"number":          {`76561197960265729`, 76561197960265729},
+		"invalid account": {`"[U:1:x]"`, 0},
+		"invalid text":    {`"STEAM_0:1:0"`, 0},
+		"wrong type":      {`true`, 0},
+	}
+
+	for name, c := range cases {
+		var id SteamID
+		err := json.Unmarshal([]byte(c.data), &id)
+
+		if id != c.want || (err == nil) != (c.want != 0) {
+			t.Errorf("%s: UnmarshalJSON(%s) = %d, %v, want %d", name, c.data, id, err, c.want)
+		}
+	}
+
+	data, err := json.Marshal(SteamID(76561197960265729))
+	if err != nil || string(data) != `"[U:1:1]"` {
+		t.Errorf("MarshalJSON() = %s, %v, want the steamID3", data, err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playerlist.json")
+
+	file := &File{
+		Schema:   SchemaURL,
+		FileInfo: FileInfo{Authors: []string{"me"}, Title: "test"},
+		Players: []Player{
+			{SteamID: 76561197960265729, Attributes: []string{"cheater"}, LastSeen: &LastSeen{PlayerName: "bot", Time: 100}, Proof: []string{"aimbot"}},
+			{SteamID: 76561197960265730, Attributes: []string{"racist"}},
+		},
+	}
+
+	if err := Write(path, file); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read, file) {
+		t.Errorf("Read() = %+v, want %+v", read, file)
+	}
+}
+
+func TestReadRejectsLists(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"no players": `{"file_info": {"authors": [], "title": "empty"}}`,
+		"not json":   `players`,
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Read(path); err == nil {
+			t.Errorf("%s: Read() accepted the playerlist", name)
+		}
+	}
+
+	if _, err := Read(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Read() accepted a missing file")
+	}
+}
@@ -0,0 +1,68 @@
+// Package protocol defines the envelope of all websocket messages exchanged with the UI-Clients.
+// The payload of every message type is described in network/schema.json.
+package protocol
+
+import (
+	"time"
+)
+
+// Version is the protocol version announced in the hello message and carried by every message.
+// It is raised whenever a payload changes incompatibly.
+const Version = 1
+
+// Message types sent to the UI-Clients
+const (
+	TypeHello          = "hello"
+	TypePlayerUpdate   = "player-update"
+	TypeFrag           = "frag"
+	TypeSuicide        = "suicide"
+	TypeServerInfo     = "server-info"
+	TypeRconStatus     = "rcon-status"
+	TypeDetection      = "detection"
+	TypeApplicationLog = "application-log"
+	TypeResponse       = "response"
+	TypeError          = "error"
+	TypeShutdown       = "shutdown"
+)
+
+// Envelope wraps every message sent to the UI-Clients
+type Envelope struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	// TS is the unix time in milliseconds the message was created
+	TS int64 `json:"ts"`
+	// ID and Request are only set on responses and errors, ID mirrors the ID of the request
+	ID      string      `json:"id,omitempty"`
+	Request string      `json:"request,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// HelloPayload is sent to every UI-Client right after it connected
+type HelloPayload struct {
+	Version int `json:"version"`
+	// Capabilities lists the request types the UI-Client may send
+	Capabilities []string `json:"capabilities"`
+	// Messages lists the message types the UI-Client may receive
+	Messages []string `json:"messages"`
+}
+
+// LogPayload is the payload of an application-log message
+type LogPayload struct {
+	Message string `json:"message"`
+}
+
+// ShutdownPayload is sent to all UI-Clients right before we close the connections and exit
+type ShutdownPayload struct {
+	Reason string `json:"reason"`
+}
+
+// New wraps the payload in an envelope of the given type
+func New(messageType string, payload interface{}) Envelope {
+	return Envelope{
+		Version: Version,
+		Type:    messageType,
+		TS:      time.Now().UnixMilli(),
+		Payload: payload,
+	}
+}
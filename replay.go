@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/replay"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// runReplay feeds an existing console.log through the full pipeline, RCON is stubbed and answers from the file itself
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	realtime := flags.Bool("realtime", false, "pace the lines like they were written")
+	speed := flags.Float64("speed", 1, "speed factor for -realtime")
+	interval := flags.Duration("interval", 50*time.Millisecond, "pause between lines without timestamp for -realtime")
+	player := flags.String("player", "", "our own player name, guessed from the log if empty")
+	withDB := flags.Bool("db", false, "also store the replayed events in the database")
+	rulesFile := flags.String("rules", "", "path to the JSON detection rules file")
+	keepOpen := flags.Bool("keep-open", false, "keep serving the websocket after the replay finished")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: replay [flags] <console.log>")
+	}
+	path := flags.Arg(0)
+
+	openConfiguredDatabase()
+
+	// A signal or a UI-Client stops the replay early
+	ctx, cancel := shutdownContext()
+	defer cancel()
+
+	// Init the grok patterns
+	utils.GrokInit()
+	loadMarks()
+	loadDetector(*rulesFile)
+
+	currentPlayer = *player
+	if currentPlayer == "" {
+		guessed, err := replay.GuessPlayer(path)
+		if err != nil {
+			log.Fatalf("Unable to read the log file: %v", err)
+		}
+		currentPlayer = guessed
+	}
+
+	log.Printf("Replaying '%s' as player '%s'", path, currentPlayer)
+
+	// Answer all RCON commands from the log
+	stub := replay.NewRcon(currentPlayer)
+	network.UseExecutor(stub)
+
+	// Start websocket for IPC with UI-Client, logs go to all clients
+	log.SetBroadcaster(network.Clients)
+	registerRequestHandlers()
+	go network.StartWebsocket(27689, onWebsocketConnectCallback)
+
+	go startWebsocketPlayerUpdater(ctx)
+
+	bus := events.NewBus()
+	subscribeWebsocket(bus)
+	subscribeConsumers(bus, *withDB)
+
+	opts := replay.Options{Realtime: *realtime, Speed: *speed, Interval: *interval}
+	err := replay.Replay(ctx, path, opts, stub, func(line string) {
+		publishLine(bus, line)
+
+		// The stub answers status once a listing in the log is complete, like the game does
+		if playersInGame.MySteamID() == 0 {
+			status, _ := stub.Execute("status")
+			resolveMe(status)
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Unable to replay the log file: %v", err)
+	}
+
+	if err == nil {
+		log.Println("Replay finished.")
+	}
+
+	if *keepOpen {
+		<-ctx.Done()
+	}
+
+	shutdown(ctx, nil, bus)
+}
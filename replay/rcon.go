@@ -0,0 +1,85 @@
+package replay
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// lobbyNotFound is the tf_lobby_debug response when we are not in a matchmaking lobby
+const lobbyNotFound = "Failed to find lobby shared object"
+
+// statusInfo matches the `key : value` lines of status, the console prints them in between the players
+var statusInfo = regexp.MustCompile(`^(?:hostname|version|udp/ip|steamid|account|map|tags|sourcetv|players|edicts)\s*:`)
+
+// Rcon is a stubbed RCON connection that answers `name`, `status` and `tf_lobby_debug` from the replayed console.log.
+// All other commands are only logged.
+type Rcon struct {
+	mu     sync.Mutex
+	player string
+	// status is the last complete status listing, listing the one still being printed
+	status  []string
+	listing []string
+	lobby   []string
+	inLobby bool
+}
+
+// NewRcon creates a stub that answers `name` with the given player
+func NewRcon(player string) *Rcon {
+	return &Rcon{player: player}
+}
+
+// Observe records the line so later commands can be answered from it
+func (r *Rcon) Observe(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line = utils.TrimCommon(line)
+
+	// Every status header starts a new status response, the first line that is neither a player row nor a status line ends it
+	switch {
+	case strings.HasPrefix(line, "# userid name"):
+		r.listing = []string{line}
+	case r.listing == nil, statusInfo.MatchString(line):
+	case strings.HasPrefix(line, "#"):
+		r.listing = append(r.listing, line)
+	default:
+		r.status = r.listing
+		r.listing = nil
+	}
+
+	// Consecutive lobby lines form one tf_lobby_debug response
+	if _, err := utils.GrokParseLobby(line); err == nil {
+		if !r.inLobby {
+			r.lobby = nil
+		}
+		r.lobby = append(r.lobby, line)
+		r.inLobby = true
+	} else {
+		r.inLobby = false
+	}
+}
+
+// Execute answers the command from the lines observed so far
+func (r *Rcon) Execute(command string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch command {
+	case "name":
+		return fmt.Sprintf(`"name" = "%s" ( def. "unnamed" )`, r.player), nil
+	case "status":
+		return strings.Join(r.status, "\n"), nil
+	case "tf_lobby_debug":
+		if len(r.lobby) == 0 {
+			return lobbyNotFound, nil
+		}
+		return strings.Join(r.lobby, "\n"), nil
+	default:
+		log.Printf("[replay] RCON: %s", command)
+		return "", nil
+	}
+}
@@ -0,0 +1,116 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/logger"
+)
+
+// Create a new instance of the logger.
+var log = logger.Logger
+
+// timestampPattern matches the prefix TF2 writes with `con_timestamp 1`
+var timestampPattern = regexp.MustCompile(`^(\d{2}/\d{2}/\d{4} - \d{2}:\d{2}:\d{2}): `)
+
+// timestampLayout is the time layout of the con_timestamp prefix
+const timestampLayout = "01/02/2006 - 15:04:05"
+
+// Options controls how a console.log is replayed
+type Options struct {
+	// Realtime paces the lines, by their con_timestamp prefix if present, otherwise Interval apart
+	Realtime bool
+	// Speed divides all pauses, 2 replays twice as fast
+	Speed float64
+	// Interval is the pause between lines without timestamp
+	Interval time.Duration
+}
+
+// Replay feeds every line of the console.log at path to the stub and then to handle, it stops early when the context is cancelled
+func Replay(ctx context.Context, path string, opts Options, stub *Rcon, handle func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	// Eventually close the file, ignore error.
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	var last time.Time
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line := scanner.Text()
+
+		// Strip the timestamp, the parsers expect the raw line
+		var at time.Time
+		if match := timestampPattern.FindStringSubmatch(line); match != nil {
+			at, _ = time.Parse(timestampLayout, match[1])
+			line = line[len(match[0]):]
+		}
+
+		if opts.Realtime {
+			pause := opts.Interval
+			if !at.IsZero() && !last.IsZero() {
+				pause = at.Sub(last)
+			}
+			select {
+			case <-time.After(time.Duration(float64(pause) / opts.Speed)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if !at.IsZero() {
+			last = at
+		}
+
+		stub.Observe(line)
+		handle(line)
+	}
+
+	return scanner.Err()
+}
+
+// GuessPlayer returns our own player name from the console.log at path.
+// The first player connecting after we join a server is ourselves.
+func GuessPlayer(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Eventually close the file, ignore error.
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	connecting := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := timestampPattern.ReplaceAllString(scanner.Text(), "")
+
+		if strings.HasPrefix(line, "Connecting to ") {
+			connecting = true
+		} else if connecting && strings.HasSuffix(line, " connected") {
+			return strings.TrimSuffix(line, " connected"), nil
+		}
+	}
+
+	return "", scanner.Err()
+}
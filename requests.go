@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// defaultHistoryLimit is the number of chats and frags returned by a history request without limit
+const defaultHistoryLimit = 50
+
+// sayRequest is the payload of the say and say-team requests
+type sayRequest struct {
+	Message string `json:"message"`
+}
+
+// playerRequest is the payload of all requests about a single player
+type playerRequest struct {
+	SteamID int64 `json:"steamID,string"`
+	Limit   int64 `json:"limit"`
+}
+
+// markRequest is the payload of the mark-player request, no attributes and notes remove the mark
+type markRequest struct {
+	SteamID    int64    `json:"steamID,string"`
+	Attributes []string `json:"attributes"`
+	Notes      string   `json:"notes"`
+}
+
+// registerRequestHandlers registers the handlers for all requests of the UI-Clients
+func registerRequestHandlers() {
+	network.HandleRequest("get-players", func(json.RawMessage) (interface{}, error) {
+		return utils.PlayerUpdate{CurrentPlayers: playersInGame.Snapshot()}, nil
+	})
+
+	network.HandleRequest("refresh-status", func(json.RawMessage) (interface{}, error) {
+		// The status output arrives in the log and updates the players from there
+		playersInGame.SetLobbyDebug(network.RconExecute("tf_lobby_debug"))
+		network.RconQueue("status", network.PriorityHigh).Wait()
+		return nil, nil
+	})
+
+	network.HandleRequest("say", func(payload json.RawMessage) (interface{}, error) {
+		return nil, say(payload, false)
+	})
+
+	network.HandleRequest("say-team", func(payload json.RawMessage) (interface{}, error) {
+		return nil, say(payload, true)
+	})
+
+	network.HandleRequest("vote-kick", func(payload json.RawMessage) (interface{}, error) {
+		var request playerRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		return nil, kicker.Kick(request.SteamID)
+	})
+
+	network.HandleRequest("mark-player", func(payload json.RawMessage) (interface{}, error) {
+		var request markRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		return markPlayer(request)
+	})
+
+	network.HandleRequest("history", func(payload json.RawMessage) (interface{}, error) {
+		var request playerRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		if request.Limit <= 0 {
+			request.Limit = defaultHistoryLimit
+		}
+
+		chats, err := db.FindChats(request.SteamID, request.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		frags, err := db.FindFrags(request.SteamID, request.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		names, err := db.FindNames(request.SteamID, request.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		return toHistoryPayload(chats, frags, names), nil
+	})
+}
+
+// say sends the chat line of a say or say-team request
+func say(payload json.RawMessage, team bool) error {
+	var request sayRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return err
+	}
+
+	if request.Message == "" {
+		return errors.New("message is empty")
+	}
+
+	network.Say(request.Message, team, network.PriorityNormal).Wait()
+	return nil
+}
+
+// markPlayer stores the mark of a mark-player request and attaches it to the player, it returns the stored mark
+func markPlayer(request markRequest) (*utils.PlayerMark, error) {
+	if request.SteamID == 0 {
+		return nil, errors.New("steamID is missing")
+	}
+
+	for _, attribute := range request.Attributes {
+		if !utils.IsMarkAttribute(attribute) {
+			return nil, fmt.Errorf("unknown attribute %q, use one of %s", attribute, strings.Join(utils.MarkAttributes, ", "))
+		}
+	}
+
+	mark := db.Mark{
+		SteamID:    request.SteamID,
+		Attributes: request.Attributes,
+		Notes:      strings.TrimSpace(request.Notes),
+		MarkedAt:   time.Now().Unix(),
+	}
+
+	if err := db.SetMark(mark); err != nil {
+		return nil, err
+	}
+
+	// Removed marks have neither attributes nor notes
+	if len(mark.Attributes) == 0 && mark.Notes == "" {
+		playersInGame.SetMark(mark.SteamID, nil)
+		return nil, nil
+	}
+
+	playerMark := toPlayerMark(mark)
+	playersInGame.SetMark(mark.SteamID, playerMark)
+
+	return playerMark, nil
+}
+
+// toPlayerMark converts a mark document for the player list
+func toPlayerMark(mark db.Mark) *utils.PlayerMark {
+	return &utils.PlayerMark{
+		Attributes: mark.Attributes,
+		Notes:      mark.Notes,
+		MarkedAt:   mark.MarkedAt,
+	}
+}
+
+// toHistoryPayload converts the documents for the history response, the steamIDs are sent as strings.
+// Chats and frags are stored with nanosecond timestamps, they are sent in seconds like the names.
+func toHistoryPayload(chats []db.Chat, frags []db.Frag, names []db.Name) utils.HistoryPayload {
+	history := utils.HistoryPayload{
+		Chats: make([]utils.ChatRecord, 0, len(chats)),
+		Frags: make([]utils.FragRecord, 0, len(frags)),
+		Names: make([]utils.NameRecord, 0, len(names)),
+	}
+
+	for _, chat := range chats {
+		history.Chats = append(history.Chats, utils.ChatRecord{
+			SteamID:   chat.SteamID,
+			Name:      chat.Name,
+			Message:   chat.Message,
+			SessionID: chat.SessionID,
+			UpdatedAt: chat.UpdatedAt / int64(time.Second),
+		})
+	}
+
+	for _, frag := range frags {
+		history.Frags = append(history.Frags, utils.FragRecord{
+			KillerSteamID: frag.KillerSteamID,
+			VictimSteamID: frag.VictimSteamID,
+			KillerName:    frag.KillerName,
+			VictimName:    frag.VictimName,
+			Weapon:        frag.Weapon,
+			Crit:          frag.Crit,
+			Map:           frag.Map,
+			SessionID:     frag.SessionID,
+			UpdatedAt:     frag.UpdatedAt / int64(time.Second),
+		})
+	}
+
+	for _, name := range names {
+		history.Names = append(history.Names, utils.NameRecord{
+			SteamID:   name.SteamID,
+			Name:      name.Name,
+			FirstSeen: name.FirstSeen,
+			LastSeen:  name.LastSeen,
+			Server:    name.Server,
+			Hostname:  name.Hostname,
+		})
+	}
+
+	return history
+}
+
+// loadMarks attaches the marks stored in the database to the players as soon as they show up
+func loadMarks() {
+	marks, err := db.FindMarks()
+	if err != nil {
+		if !errors.Is(err, db.ErrDisabled) {
+			log.Printf("Unable to load the player marks: %v", err)
+		}
+		return
+	}
+
+	for _, mark := range marks {
+		playersInGame.SetMark(mark.SteamID, toPlayerMark(mark))
+	}
+
+	log.Printf("Loaded %d player marks", len(marks))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/db"
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// TestHistoryTimestamps stores a chat and a frag like the program does and checks the history sends seconds
+func TestHistoryTimestamps(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := db.Open(db.Options{Backend: db.BackendFile, Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus()
+	db.Subscribe(bus)
+
+	before := time.Now().Unix()
+	bus.Publish(events.ChatMessage{Chat: &utils.ChatInfo{PlayerName: "someone", Message: "gg"}, SteamID: 76561198000000001, SessionID: "abc"})
+	bus.Publish(events.Frag{Info: &utils.FragInfo{KillerName: "someone", VictimName: "other", KillerSteamID: "76561198000000001", Weapon: "scattergun"}, SessionID: "abc"})
+	after := time.Now().Unix()
+
+	// Closing writes the queue, reopening reads what was stored
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Open(db.Options{Backend: db.BackendFile, Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	chats, err := db.FindChats(76561198000000001, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frags, err := db.FindFrags(76561198000000001, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := toHistoryPayload(chats, frags, nil)
+	if len(history.Chats) != 1 || len(history.Frags) != 1 {
+		t.Fatalf("got %d chats and %d frags, want one of each", len(history.Chats), len(history.Frags))
+	}
+
+	for what, updatedAt := range map[string]int64{"chat": history.Chats[0].UpdatedAt, "frag": history.Frags[0].UpdatedAt} {
+		if updatedAt < before || updatedAt > after {
+			t.Errorf("the %s was sent with UpdatedAt %d, want a unix timestamp between %d and %d", what, updatedAt, before, after)
+		}
+	}
+}
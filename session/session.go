@@ -0,0 +1,189 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Tracker keeps the session of the server we are currently connected to
+type Tracker struct {
+	mu      sync.Mutex
+	current *utils.ServerSession
+}
+
+// NewTracker creates a tracker without a current session
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Update applies the given console line to the current session.
+// It returns every session that changed because of it, an ended session comes before the one replacing it.
+func (t *Tracker) Update(line string) []utils.ServerSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Joining a server always starts a new session
+	if address, err := utils.GrokParseConnecting(line); err == nil {
+		var changed []utils.ServerSession
+
+		if ended := t.end(); ended != nil {
+			changed = append(changed, *ended)
+		}
+
+		t.current = &utils.ServerSession{
+			ID:        newID(),
+			Address:   address,
+			StartedAt: time.Now().Unix(),
+		}
+
+		return append(changed, t.snapshot())
+	}
+
+	// Leaving the server ends the session, the lines until the next join belong to none
+	if _, err := utils.GrokParseDisconnect(line); err == nil {
+		if ended := t.end(); ended != nil {
+			return []utils.ServerSession{*ended}
+		}
+
+		return nil
+	}
+
+	if address, err := utils.GrokParseServerAddress(line); err == nil {
+		return t.set(func(s *utils.ServerSession) bool {
+			return setString(&s.Address, address)
+		})
+	}
+
+	if mapName, err := utils.GrokParseMap(line); err == nil {
+		return t.set(func(s *utils.ServerSession) bool {
+			return setString(&s.Map, mapName)
+		})
+	}
+
+	if hostname, err := utils.GrokParseHostname(line); err == nil {
+		return t.set(func(s *utils.ServerSession) bool {
+			return setString(&s.Hostname, hostname)
+		})
+	}
+
+	if tags, err := utils.GrokParseTags(line); err == nil {
+		return t.set(func(s *utils.ServerSession) bool {
+			if sameTags(s.Tags, tags) {
+				return false
+			}
+
+			s.Tags = tags
+			return true
+		})
+	}
+
+	if steamID, err := utils.GrokParseServerSteamID(line); err == nil {
+		return t.set(func(s *utils.ServerSession) bool {
+			if s.SteamID == steamID {
+				return false
+			}
+
+			s.SteamID = steamID
+			return true
+		})
+	}
+
+	return nil
+}
+
+// Current returns a copy of the current session, false if we haven't seen a server yet
+func (t *Tracker) Current() (utils.ServerSession, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current == nil {
+		return utils.ServerSession{}, false
+	}
+
+	return t.snapshot(), true
+}
+
+// End ends the current session and returns it, nil if there is none
+func (t *Tracker) End() *utils.ServerSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.end()
+}
+
+// end ends the current session, the caller must hold the lock
+func (t *Tracker) end() *utils.ServerSession {
+	if t.current == nil {
+		return nil
+	}
+
+	ended := t.snapshot()
+	ended.EndedAt = time.Now().Unix()
+	t.current = nil
+
+	return &ended
+}
+
+// set applies the change to the current session, starting one if the program was started mid-game.
+// The caller must hold the lock.
+func (t *Tracker) set(change func(*utils.ServerSession) bool) []utils.ServerSession {
+	if t.current == nil {
+		t.current = &utils.ServerSession{
+			ID:        newID(),
+			StartedAt: time.Now().Unix(),
+		}
+	}
+
+	if !change(t.current) {
+		return nil
+	}
+
+	return []utils.ServerSession{t.snapshot()}
+}
+
+// snapshot copies the current session, the caller must hold the lock
+func (t *Tracker) snapshot() utils.ServerSession {
+	s := *t.current
+	s.Tags = append([]string(nil), t.current.Tags...)
+	return s
+}
+
+// setString sets the field to the value and reports whether it changed
+func setString(field *string, value string) bool {
+	if *field == value {
+		return false
+	}
+
+	*field = value
+	return true
+}
+
+// sameTags reports whether both tag lists are equal
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newID generates a random session ID, falling back to the current time
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// consoleLines returns the lines of the console.log fixture, it joins three servers one after another
+func consoleLines(t *testing.T) []string {
+	t.Helper()
+
+	data, err := os.ReadFile("../test/fixtures/console.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+}
+
+func TestTrackerFollowsConsole(t *testing.T) {
+	utils.GrokInit()
+
+	tracker := NewTracker()
+
+	var changes []utils.ServerSession
+	for _, line := range append(consoleLines(t), "Disconnect: Server shutting down") {
+		changes = append(changes, tracker.Update(line)...)
+	}
+
+	// The last state of every session, in the order they started
+	var sessions []utils.ServerSession
+	for _, change := range changes {
+		if n := len(sessions); n > 0 && sessions[n-1].ID == change.ID {
+			sessions[n-1] = change
+		} else {
+			sessions = append(sessions, change)
+		}
+	}
+
+	want := []utils.ServerSession{
+		{
+			Address:  "135.125.189.220:27025",
+			Hostname: "Uncletopia | Frankfurt | 2 | All Maps",
+			Map:      "cp_snakewater_final1",
+			Tags:     []string{"cp", "nocrits", "nodmgspread", "uncletopia"},
+			SteamID:  85568392924469990,
+		},
+		{
+			Address:  "135.125.189.220:27085",
+			Hostname: "Uncletopia | Frankfurt | 8 | Community",
+			Map:      "workshop/cp_reckoner_rc6.ugc674719999",
+			Tags:     []string{"cp", "nocrits", "nodmgspread", "uncletopia"},
+			SteamID:  85568392924742828,
+		},
+		{
+			Address:  "51.195.189.144:27045",
+			Hostname: "Uncletopia | London | 4 | All Maps",
+			Map:      "pl_frontier_final",
+			Tags:     []string{"nocrits", "nodmgspread", "payload", "uncletopia"},
+			SteamID:  85568392924510193,
+		},
+	}
+
+	if len(sessions) != len(want) {
+		t.Fatalf("%d sessions, want one per server: %+v", len(sessions), sessions)
+	}
+
+	for i, session := range sessions {
+		if session.ID == "" || session.StartedAt == 0 || session.EndedAt < session.StartedAt {
+			t.Errorf("session %d started at %d and ended at %d with the ID %q", i, session.StartedAt, session.EndedAt, session.ID)
+		}
+
+		session.ID, session.StartedAt, session.EndedAt = "", 0, 0
+		if !reflect.DeepEqual(session, want[i]) {
+			t.Errorf("session %d = %+v, want %+v", i, session, want[i])
+		}
+	}
+
+	if current, ok := tracker.Current(); ok {
+		t.Errorf("the session %+v is still open after the disconnect", current)
+	}
+}
+
+func TestTrackerUpdate(t *testing.T) {
+	utils.GrokInit()
+
+	tracker := NewTracker()
+
+	// Started mid-game, the status output opens a session without an address
+	changed := tracker.Update("hostname: Uncletopia | London | 4 | All Maps")
+	if len(changed) != 1 || changed[0].Hostname != "Uncletopia | London | 4 | All Maps" || changed[0].Address != "" {
+		t.Fatalf("Update(hostname) = %+v, want a new session", changed)
+	}
+	first := changed[0].ID
+
+	if changed := tracker.Update("hostname: Uncletopia | London | 4 | All Maps"); changed != nil {
+		t.Errorf("Update() without a change = %+v, want nothing", changed)
+	}
+	if changed := tracker.Update("atomy :  hostname: fake"); changed != nil {
+		t.Errorf("Update(chat) = %+v, want nothing", changed)
+	}
+
+	// Joining the next server ends the last session first
+	changed = tracker.Update("Connecting to 51.195.189.144:27045...")
+	if len(changed) != 2 || changed[0].ID != first || changed[0].EndedAt == 0 || changed[1].ID == first || changed[1].Address != "51.195.189.144:27045" || changed[1].EndedAt != 0 {
+		t.Fatalf("Update(connecting) = %+v, want the old session ended and a new one", changed)
+	}
+
+	if current, ok := tracker.Current(); !ok || current.ID != changed[1].ID || current.Hostname != "" {
+		t.Errorf("Current() = %+v, %v, want the new session", current, ok)
+	}
+
+	ended := tracker.End()
+	if ended == nil || ended.ID != changed[1].ID || ended.EndedAt == 0 {
+		t.Fatalf("End() = %+v, want the new session ended", ended)
+	}
+
+	if ended := tracker.End(); ended != nil {
+		t.Errorf("End() twice = %+v, want nil", ended)
+	}
+	if changed := tracker.Update("Disconnect: Kicked by Console"); changed != nil {
+		t.Errorf("Update(disconnect) without a session = %+v, want nothing", changed)
+	}
+}
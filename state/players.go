@@ -0,0 +1,316 @@
+package state
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// lobbyNotFound is the tf_lobby_debug response when we are not in a matchmaking lobby
+const lobbyNotFound = "Failed to find lobby shared object"
+
+// PlayerRegistry holds the players currently in the game, it is safe for concurrent use
+type PlayerRegistry struct {
+	mu           sync.RWMutex
+	players      []*utils.PlayerInfo
+	lobbyPlayers []utils.LobbyDebugPlayer
+	marks        map[int64]*utils.PlayerMark
+	encounters   map[int64]*utils.Encounters
+	// me is the steamID64 of the local player, 0 until it was resolved
+	me         int64
+	lastUpdate int64
+	dirty      bool
+}
+
+// NewPlayerRegistry creates an empty registry
+func NewPlayerRegistry() *PlayerRegistry {
+	return &PlayerRegistry{
+		marks:      make(map[int64]*utils.PlayerMark),
+		encounters: make(map[int64]*utils.Encounters),
+	}
+}
+
+// SetMe sets the steamID64 of the local player, used to flag ourselves with IsMe.
+// Names can be copied, the steamID64 can't.
+func (r *PlayerRegistry) SetMe(steamID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.me = steamID
+	for _, player := range r.players {
+		if isMe := player.SteamID == steamID; player.IsMe != isMe {
+			player.IsMe = isMe
+			r.dirty = true
+		}
+	}
+}
+
+// MySteamID returns the steamID64 of the local player, 0 until SetMe was called
+func (r *PlayerRegistry) MySteamID() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.me
+}
+
+// SetLobbyDebug stores the last tf_lobby_debug response, its team info is merged into upserted players
+func (r *PlayerRegistry) SetLobbyDebug(response string) {
+	var lobbyPlayers []utils.LobbyDebugPlayer
+
+	if response != lobbyNotFound {
+		lobbyPlayers = utils.ParseLobbyResponse(response)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lobbyPlayers = lobbyPlayers
+}
+
+// SetMark attaches the mark to the player with the given steamID64, now and whenever they show up again, nil removes it
+func (r *PlayerRegistry) SetMark(steamID int64, mark *utils.PlayerMark) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if mark == nil {
+		delete(r.marks, steamID)
+	} else {
+		// Marks are shared by the player copies, never change the caller's
+		stored := *mark
+		mark = &stored
+		r.marks[steamID] = mark
+	}
+
+	for _, player := range r.players {
+		if player.SteamID == steamID {
+			player.Mark = mark
+			r.dirty = true
+		}
+	}
+}
+
+// SetEncounters attaches the earlier encounters to the player with the given steamID64, now and whenever they show up again
+func (r *PlayerRegistry) SetEncounters(steamID int64, encounters *utils.Encounters) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Encounters are shared by the player copies, never change the caller's
+	stored := *encounters
+	r.encounters[steamID] = &stored
+
+	for _, player := range r.players {
+		if player.SteamID == steamID {
+			player.Encounters = &stored
+			r.dirty = true
+		}
+	}
+}
+
+// Upsert adds the player or replaces the entry with the same SteamID
+func (r *PlayerRegistry) Upsert(playerInfo *utils.PlayerInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Never keep a pointer the caller still holds
+	player := *playerInfo
+
+	// Find ourselves and set flag to true.
+	player.IsMe = r.me != 0 && player.SteamID == r.me
+
+	if lobbyPlayer := utils.FindLobbyPlayerBySteamId(r.lobbyPlayers, player.SteamID); lobbyPlayer != nil {
+		player.Team = lobbyPlayer.Team
+		player.Type = lobbyPlayer.Type
+		player.MemberType = lobbyPlayer.MemberType
+	}
+
+	player.Mark = r.marks[player.SteamID]
+	player.Encounters = r.encounters[player.SteamID]
+
+	r.dirty = true
+
+	// Check if the player already exists in the list
+	for i, existingPlayer := range r.players {
+		if existingPlayer.SteamID == player.SteamID {
+			// Player already exists, update the fields
+			// Preserve tf-lobby-fields if new ones are empty
+			if len(player.Team) <= 0 {
+				player.Team = existingPlayer.Team
+			}
+
+			if len(player.Type) <= 0 {
+				player.Type = existingPlayer.Type
+			}
+
+			if len(player.MemberType) <= 0 {
+				player.MemberType = existingPlayer.MemberType
+			}
+
+			r.players[i] = &player
+			return
+		}
+	}
+
+	r.players = append(r.players, &player)
+	r.lastUpdate = time.Now().Unix()
+}
+
+// Expire discards all players that haven't been seen for longer than maxAge and returns their steamID64s.
+// Their encounters are discarded as well, they are loaded again when the players come back.
+func (r *PlayerRegistry) Expire(maxAge time.Duration) []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var activePlayers []*utils.PlayerInfo
+	var expired []int64
+	oldest := time.Now().Add(-maxAge).Unix()
+
+	for _, existingPlayer := range r.players {
+		if existingPlayer.LastSeen >= oldest {
+			activePlayers = append(activePlayers, existingPlayer)
+		} else {
+			expired = append(expired, existingPlayer.SteamID)
+			delete(r.encounters, existingPlayer.SteamID)
+		}
+	}
+
+	if len(expired) > 0 {
+		r.dirty = true
+	}
+
+	r.players = activePlayers
+
+	return expired
+}
+
+// LookupByName returns a copy of the player with the given name. Players sharing the exact name are ambiguous,
+// the line could have come from any of them, so none is returned rather than blaming the one who joined first.
+func (r *PlayerRegistry) LookupByName(name string) (*utils.PlayerInfo, bool) {
+	named := r.Named(name)
+	if len(named) != 1 {
+		return nil, false
+	}
+
+	return named[0], true
+}
+
+// Attribute returns a copy of the player who wrote a chat or frag line with the given name at the given time,
+// and all players who could have written it in join order. Players who joined after the line are ruled out.
+// If several are left, the line goes to the one who joined last: the newer account is the impersonator,
+// and a copier's lines must never be blamed on the original.
+func (r *PlayerRegistry) Attribute(name string, at time.Time) (*utils.PlayerInfo, []*utils.PlayerInfo) {
+	var candidates []*utils.PlayerInfo
+	for _, player := range r.Named(name) {
+		if joinedAt(player) <= at.Unix() {
+			candidates = append(candidates, player)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	return candidates[len(candidates)-1], candidates
+}
+
+// joinedAt returns when the player joined the server, from the connected time of their last status line
+func joinedAt(player *utils.PlayerInfo) int64 {
+	var seconds int64
+	for _, part := range strings.Split(player.Connected, ":") {
+		value, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			// Bots have no connected time
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+
+	return player.LastSeen - seconds
+}
+
+// Named returns copies of all players with exactly the given name, in the order they joined the server
+func (r *PlayerRegistry) Named(name string) []*utils.PlayerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var named []*utils.PlayerInfo
+	for _, player := range r.players {
+		if player.Name == name {
+			found := *player
+			named = append(named, &found)
+		}
+	}
+
+	// The server hands out user IDs in join order
+	sort.Slice(named, func(i, j int) bool {
+		return named[i].UserID < named[j].UserID
+	})
+
+	return named
+}
+
+// LookupBySteamID returns a copy of the player with the given steamID64
+func (r *PlayerRegistry) LookupBySteamID(steamID int64) (*utils.PlayerInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, player := range r.players {
+		if player.SteamID == steamID {
+			found := *player
+			return &found, true
+		}
+	}
+
+	return nil, false
+}
+
+// Me returns a copy of the local player
+func (r *PlayerRegistry) Me() (*utils.PlayerInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, player := range r.players {
+		if player.IsMe {
+			found := *player
+			return &found, true
+		}
+	}
+
+	return nil, false
+}
+
+// Snapshot returns copies of all players currently in the game
+func (r *PlayerRegistry) Snapshot() []*utils.PlayerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	players := make([]*utils.PlayerInfo, 0, len(r.players))
+	for _, player := range r.players {
+		found := *player
+		players = append(players, &found)
+	}
+
+	return players
+}
+
+// LastUpdate returns the unix time a new player was last added
+func (r *PlayerRegistry) LastUpdate() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastUpdate
+}
+
+// TakeDirty reports whether the players changed since the last call and resets the flag
+func (r *PlayerRegistry) TakeDirty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dirty := r.dirty
+	r.dirty = false
+
+	return dirty
+}
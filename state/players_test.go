@@ -0,0 +1,256 @@
+package state
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// player returns a player seen now with the given steamID64, user ID and name
+func player(steamID int64, userID int, name string) *utils.PlayerInfo {
+	return &utils.PlayerInfo{SteamID: steamID, UserID: userID, Name: name, LastSeen: time.Now().Unix()}
+}
+
+func TestUpsertReplacesBySteamID(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	r.Upsert(player(1, 10, "first"))
+	r.Upsert(player(2, 11, "second"))
+	r.Upsert(player(1, 10, "renamed"))
+
+	players := r.Snapshot()
+	if len(players) != 2 {
+		t.Fatalf("got %d players, want 2", len(players))
+	}
+
+	found, ok := r.LookupBySteamID(1)
+	if !ok || found.Name != "renamed" {
+		t.Fatalf("LookupBySteamID(1) = %+v, %v, want the renamed player", found, ok)
+	}
+}
+
+func TestUpsertKeepsLobbyFields(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	withTeam := player(1, 10, "first")
+	withTeam.Team = "TF_GC_TEAM_DEFENDERS"
+	r.Upsert(withTeam)
+	r.Upsert(player(1, 10, "first"))
+
+	found, _ := r.LookupBySteamID(1)
+	if found.Team != "TF_GC_TEAM_DEFENDERS" {
+		t.Fatalf("Team = %q, want it kept from the earlier upsert", found.Team)
+	}
+}
+
+func TestUpsertNeverKeepsCallerPointer(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	p := player(1, 10, "first")
+	r.Upsert(p)
+	p.Name = "changed"
+
+	snapshot := r.Snapshot()
+	snapshot[0].Name = "changed too"
+
+	found, _ := r.LookupBySteamID(1)
+	if found.Name != "first" {
+		t.Fatalf("Name = %q, the registry shares its player with the caller", found.Name)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	old := player(1, 10, "old")
+	old.LastSeen = time.Now().Add(-time.Minute).Unix()
+	r.Upsert(old)
+	r.Upsert(player(2, 11, "recent"))
+	r.SetEncounters(1, &utils.Encounters{Sessions: 3})
+	r.SetEncounters(2, &utils.Encounters{Sessions: 4})
+	r.TakeDirty()
+
+	expired := r.Expire(30 * time.Second)
+	if len(expired) != 1 || expired[0] != 1 {
+		t.Fatalf("Expire() = %v, want steamID 1", expired)
+	}
+
+	if _, ok := r.LookupBySteamID(1); ok {
+		t.Fatal("the old player was not expired")
+	}
+	if _, ok := r.LookupBySteamID(2); !ok {
+		t.Fatal("the recent player was expired")
+	}
+	if !r.TakeDirty() {
+		t.Fatal("expiring a player did not mark the registry dirty")
+	}
+
+	// The encounters of a player who left are stale once they come back
+	r.Upsert(player(1, 12, "old"))
+	if found, _ := r.LookupBySteamID(1); found.Encounters != nil {
+		t.Fatalf("Encounters = %+v, the expired player kept their encounters", found.Encounters)
+	}
+	if found, _ := r.LookupBySteamID(2); found.Encounters == nil || found.Encounters.Sessions != 4 {
+		t.Fatalf("Encounters = %+v, the recent player lost their encounters", found.Encounters)
+	}
+}
+
+func TestNamedJoinOrder(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	r.Upsert(player(3, 30, "twin"))
+	r.Upsert(player(1, 10, "twin"))
+	r.Upsert(player(2, 20, "other"))
+
+	named := r.Named("twin")
+	if len(named) != 2 || named[0].UserID != 10 || named[1].UserID != 30 {
+		t.Fatalf("Named(twin) = %+v, want user IDs 10 and 30 in that order", named)
+	}
+
+	if _, ok := r.LookupByName("twin"); ok {
+		t.Fatal("LookupByName resolved a name shared by two players")
+	}
+	if found, ok := r.LookupByName("other"); !ok || found.SteamID != 2 {
+		t.Fatalf("LookupByName(other) = %+v, %v, want steamID 2", found, ok)
+	}
+}
+
+func TestMarkFollowsPlayer(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	r.SetMark(1, &utils.PlayerMark{Attributes: []string{utils.MarkCheater}})
+	r.Upsert(player(1, 10, "cheater"))
+
+	found, _ := r.LookupBySteamID(1)
+	if !found.Mark.Has(utils.MarkCheater) {
+		t.Fatalf("Mark = %+v, want the mark set before the player joined", found.Mark)
+	}
+
+	r.SetMark(1, nil)
+	found, _ = r.LookupBySteamID(1)
+	if found.Mark != nil {
+		t.Fatalf("Mark = %+v, want it removed", found.Mark)
+	}
+}
+
+// TestConcurrentUse is meant for go test -race, the registry is used by the log, the watcher and the websocket at once
+func TestConcurrentUse(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	const players = 24
+	const rounds = 200
+
+	var wg sync.WaitGroup
+	run := func(work func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				work(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		id := int64(i % players)
+		r.Upsert(player(id, int(id), "player"+strconv.FormatInt(id%4, 10)))
+	})
+	run(func(i int) {
+		r.Expire(time.Hour)
+	})
+	run(func(i int) {
+		for _, p := range r.Snapshot() {
+			p.Name = "mine now"
+		}
+	})
+	run(func(i int) {
+		r.Named("player" + strconv.Itoa(i%4))
+		r.LookupByName("player0")
+	})
+	run(func(i int) {
+		r.SetMark(int64(i%players), &utils.PlayerMark{Attributes: []string{utils.MarkSuspicious}})
+		r.LookupBySteamID(int64(i % players))
+	})
+	run(func(i int) {
+		r.Me()
+		r.TakeDirty()
+		r.LastUpdate()
+	})
+
+	wg.Wait()
+
+	snapshot := r.Snapshot()
+	if len(snapshot) != players {
+		t.Fatalf("got %d players, want %d", len(snapshot), players)
+	}
+
+	for _, p := range snapshot {
+		if p.Name == "mine now" {
+			t.Fatal("a change to a snapshot leaked into the registry")
+		}
+	}
+}
+
+func TestSetMeBySteamID(t *testing.T) {
+	r := NewPlayerRegistry()
+
+	r.Upsert(player(1, 10, "me"))
+	r.SetMe(1)
+	// Someone joined with our name
+	r.Upsert(player(2, 11, "me"))
+
+	me, ok := r.Me()
+	if !ok || me.SteamID != 1 {
+		t.Fatalf("Me() = %+v, %v, want steamID 1", me, ok)
+	}
+
+	copier, _ := r.LookupBySteamID(2)
+	if copier.IsMe {
+		t.Fatal("the player who copied our name is flagged as us")
+	}
+	if r.MySteamID() != 1 {
+		t.Fatalf("MySteamID() = %d, want 1", r.MySteamID())
+	}
+}
+
+func TestAttributeByJoinOrder(t *testing.T) {
+	r := NewPlayerRegistry()
+	now := time.Now()
+
+	original := player(1, 10, "twin")
+	original.Connected = "1:00:00"
+	copier := player(2, 30, "twin")
+	copier.Connected = "01:00"
+	bot := player(3, 2, "Uncletopia")
+	r.Upsert(original)
+	r.Upsert(copier)
+	r.Upsert(bot)
+
+	cases := []struct {
+		name       string
+		at         time.Time
+		want       int64
+		candidates int
+	}{
+		{"twin", now, 2, 2},
+		{"twin", now.Add(-5 * time.Minute), 1, 1},
+		{"twin", now.Add(-2 * time.Hour), 0, 0},
+		{"Uncletopia", now.Add(-2 * time.Hour), 3, 1},
+		{"nobody", now, 0, 0},
+	}
+
+	for _, c := range cases {
+		found, candidates := r.Attribute(c.name, c.at)
+
+		got := int64(0)
+		if found != nil {
+			got = found.SteamID
+		}
+		if got != c.want || len(candidates) != c.candidates {
+			t.Errorf("Attribute(%s, %s ago) = %d of %d candidates, want %d of %d", c.name, now.Sub(c.at), got, len(candidates), c.want, c.candidates)
+		}
+	}
+}
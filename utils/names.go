@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// homoglyphs maps characters that look like latin letters or digits to them, the usual tools of name-stealers
+var homoglyphs = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'B', 'е': 'e', 'к': 'k', 'м': 'M', 'н': 'H', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 'T', 'у': 'y', 'х': 'x',
+	'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ӏ': 'l', 'ԛ': 'q', 'ԝ': 'w',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X',
+	'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'Ү': 'Y', 'Ԁ': 'D', 'Ӏ': 'l',
+	// Greek
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'κ': 'k', 'ι': 'i',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// Lookalikes within latin
+	'ı': 'i', 'ȷ': 'j', 'ɡ': 'g', 'ℓ': 'l',
+}
+
+// IsInvisible reports whether the rune renders as nothing, like zero-width spaces and the Hangul fillers
+func IsInvisible(r rune) bool {
+	switch r {
+	case '\u115F', '\u1160', '\u3164', '\uFFA0', '\u2800':
+		return true
+	}
+
+	return unicode.Is(unicode.Cf, r)
+}
+
+// CanonicalName returns the name as it looks on screen: invisible characters and surrounding spaces are removed,
+// fullwidth and homoglyph characters are replaced by the latin letters they imitate
+func CanonicalName(name string) string {
+	canonical := strings.Map(func(r rune) rune {
+		if IsInvisible(r) {
+			return -1
+		}
+
+		// Fullwidth forms of ASCII
+		if r >= '\uFF01' && r <= '\uFF5E' {
+			return r - '\uFF01' + '!'
+		}
+
+		if latin, ok := homoglyphs[r]; ok {
+			return latin
+		}
+
+		return r
+	}, name)
+
+	return strings.TrimSpace(canonical)
+}
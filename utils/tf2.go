@@ -21,29 +21,54 @@ var log = logger.Logger
 
 // Global variables
 const (
-	grokPattern           = `^# +%{NUMBER:userId} %{QS:userName} +\[%{WORD:steamAccType}:%{NUMBER:steamUniverse}:%{NUMBER:steamID32}\] +%{CONNECTED_TIME:connectedTime} +%{NUMBER:ping} +%{NUMBER:loss} +%{WORD:state}$`
-	grokPlayerNamePattern = `%{QS}%{SPACE}=%{SPACE}%{QS:playerName}%{SPACE}\(%{SPACE}def\.%{SPACE}%{QS}%{SPACE}\)%{GREEDYDATA}`
-	grokCommandPattern    = `!%{WORD:command}\s{1}%{GREEDYDATA:args}(?:\r?\n?)?$`
-	grokChatPattern       = `(?:(?:\*DEAD\*(?:\(TEAM\))?)|(?:\(TEAM\)))?(?:\s{1})?%{GREEDYDATA:player_name}\s{1}:\s{2}%{GREEDYDATA:message}$`
-	grokLobbyPattern      = `^ +%{WORD:memberType}\[[0-9]+\] +\[%{WORD:steamAccType}:%{NUMBER:steamUniverse}:%{NUMBER:steamID32}\] +team = %{WORD:team} +type = %{WORD:type}$`
-	grokFragPattern       = `^%{GREEDYDATA:killer_name} killed %{GREEDYDATA:victim_name} with %{DATA:weapon}\.%{SPACE}*(%{DATA:crit})?$`
+	grokPattern             = `^# +%{NUMBER:userId} %{QS:userName} +\[%{WORD:steamAccType}:%{NUMBER:steamUniverse}:%{NUMBER:steamID32}\] +%{CONNECTED_TIME:connectedTime} +%{NUMBER:ping} +%{NUMBER:loss} +%{WORD:state}$`
+	grokPlayerNamePattern   = `%{QS}%{SPACE}=%{SPACE}%{QS:playerName}%{SPACE}\(%{SPACE}def\.%{SPACE}%{QS}%{SPACE}\)%{GREEDYDATA}`
+	grokCommandPattern      = `^!%{WORD:command}(?:\s+%{GREEDYDATA:args})?$`
+	grokChatPattern         = `(?:(?:\*DEAD\*(?:\(TEAM\))?)|(?:\(TEAM\)))?(?:\s{1})?%{GREEDYDATA:player_name}\s{1}:\s{2}%{GREEDYDATA:message}$`
+	grokLobbyPattern        = `^ +%{WORD:memberType}\[[0-9]+\] +\[%{WORD:steamAccType}:%{NUMBER:steamUniverse}:%{NUMBER:steamID32}\] +team = %{WORD:team} +type = %{WORD:type}$`
+	grokFragPattern         = `^%{GREEDYDATA:killer_name} killed %{GREEDYDATA:victim_name} with %{DATA:weapon}\.%{SPACE}*(%{DATA:crit})?$`
+	grokSuicidePattern      = `^%{GREEDYDATA:player_name} suicided\.$`
+	grokMapPattern          = `^(?:Map: |map +: )%{NOTSPACE:map}`
+	grokConnectingPattern   = `^Connecting to %{NOTSPACE:address}\.\.\.$`
+	grokDisconnectPattern   = `^Disconnect(?:: %{GREEDYDATA:reason}|ing from abandoned match server)$`
+	grokAddressPattern      = `^(?:Connected to |udp/ip +: )%{NOTSPACE:address}$`
+	grokHostnamePattern     = `^hostname: %{GREEDYDATA:hostname}$`
+	grokTagsPattern         = `^tags +: %{GREEDYDATA:tags}$`
+	grokServerIDPattern     = `^steamid +: \[G:%{NUMBER}:%{NUMBER}\] \(%{NUMBER:steamID}\)$`
+	grokVoteCooldownPattern = `^(?:This vote failed recently\. )?Wait %{INT:amount} %{WORD:unit}(?: before calling another vote)?\.$`
 )
 
-// %TODO, parse suicides
-
 var (
-	g            *grok.Grok
-	gc           *grok.CompiledGrok
-	gPlayerName  *grok.Grok
-	gcPlayerName *grok.CompiledGrok
-	gChat        *grok.Grok
-	gcChat       *grok.CompiledGrok
-	gFrag        *grok.Grok
-	gcFrag       *grok.CompiledGrok
-	gLobby       *grok.Grok
-	gcLobby      *grok.CompiledGrok
-	gCommands    *grok.Grok
-	gcCommands   *grok.CompiledGrok
+	g              *grok.Grok
+	gc             *grok.CompiledGrok
+	gPlayerName    *grok.Grok
+	gcPlayerName   *grok.CompiledGrok
+	gChat          *grok.Grok
+	gcChat         *grok.CompiledGrok
+	gFrag          *grok.Grok
+	gcFrag         *grok.CompiledGrok
+	gSuicide       *grok.Grok
+	gcSuicide      *grok.CompiledGrok
+	gMap           *grok.Grok
+	gcMap          *grok.CompiledGrok
+	gConnecting    *grok.Grok
+	gcConnecting   *grok.CompiledGrok
+	gDisconnect    *grok.Grok
+	gcDisconnect   *grok.CompiledGrok
+	gAddress       *grok.Grok
+	gcAddress      *grok.CompiledGrok
+	gHostname      *grok.Grok
+	gcHostname     *grok.CompiledGrok
+	gTags          *grok.Grok
+	gcTags         *grok.CompiledGrok
+	gServerID      *grok.Grok
+	gcServerID     *grok.CompiledGrok
+	gVoteCooldown  *grok.Grok
+	gcVoteCooldown *grok.CompiledGrok
+	gLobby         *grok.Grok
+	gcLobby        *grok.CompiledGrok
+	gCommands      *grok.Grok
+	gcCommands     *grok.CompiledGrok
 )
 
 // PlayerInfo is a struct containing all the info we need about a player
@@ -62,18 +87,128 @@ type PlayerInfo struct {
 	MemberType    string
 	Type          string
 	IsMe          bool
+	Mark          *PlayerMark `json:",omitempty"`
+	Encounters    *Encounters `json:",omitempty"`
+}
+
+// Encounters is a struct summing up the earlier server sessions we shared with a player, timestamps are unix timestamps
+type Encounters struct {
+	Sessions  int
+	Minutes   int64
+	Servers   int
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// Attributes a player can be marked with
+const (
+	MarkCheater    = "cheater"
+	MarkSuspicious = "suspicious"
+	MarkBot        = "bot"
+	MarkRacist     = "racist"
+	MarkExploiter  = "exploiter"
+	MarkFriend     = "friend"
+	// MarkTrusted players may run the chat commands reserved for trusted players
+	MarkTrusted = "trusted"
+)
+
+// MarkAttributes lists all attributes a player can be marked with
+var MarkAttributes = []string{MarkCheater, MarkSuspicious, MarkBot, MarkRacist, MarkExploiter, MarkFriend, MarkTrusted}
+
+// PlayerMark is a struct containing what we noted about a player, MarkedAt is a unix timestamp
+type PlayerMark struct {
+	Attributes []string
+	Notes      string
+	MarkedAt   int64
+}
+
+// Has reports whether the mark has the attribute, a nil mark has none
+func (m *PlayerMark) Has(attribute string) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, a := range m.Attributes {
+		if a == attribute {
+			return true
+		}
+	}
+
+	return false
 }
 
-// PlayerUpdate is a struct for player-updates over websockets, it has its dedicated type
+// PlayerUpdate is the payload of player-updates over websockets
 type PlayerUpdate struct {
-	Type           string        `json:"type"`
-	CurrentPlayers []*PlayerInfo `json:"current-players"`
+	CurrentPlayers []*PlayerInfo `json:"currentPlayers"`
+}
+
+// HistoryPayload is the payload of the response to a history request
+type HistoryPayload struct {
+	Chats []ChatRecord `json:"chats"`
+	Frags []FragRecord `json:"frags"`
+	Names []NameRecord `json:"names"`
+}
+
+// ChatRecord is a struct containing a stored chat message, UpdatedAt is a unix timestamp
+type ChatRecord struct {
+	SteamID   int64 `json:"SteamID,string"`
+	Name      string
+	Message   string
+	SessionID string
+	UpdatedAt int64
+}
+
+// FragRecord is a struct containing a stored frag, UpdatedAt is a unix timestamp
+type FragRecord struct {
+	KillerSteamID int64 `json:"KillerSteamID,string"`
+	VictimSteamID int64 `json:"VictimSteamID,string"`
+	KillerName    string
+	VictimName    string
+	Weapon        string
+	Crit          bool
+	Map           string
+	SessionID     string
+	UpdatedAt     int64
+}
+
+// NameRecord is a struct containing a name a player used and where, the timestamps are unix timestamps
+type NameRecord struct {
+	SteamID   int64 `json:"SteamID,string"`
+	Name      string
+	FirstSeen int64
+	LastSeen  int64
+	Server    string
+	Hostname  string
+}
+
+// ServerSession is a struct containing all the info we need about the server we are playing on
+type ServerSession struct {
+	ID        string
+	Address   string
+	Hostname  string
+	Map       string
+	Tags      []string
+	SteamID   int64 `json:"SteamID,string"`
+	StartedAt int64
+	EndedAt   int64
+}
+
+// RconStatusInfo is a struct containing the state of the RCON connection
+type RconStatusInfo struct {
+	Status  string
+	Attempt int
+	RetryIn int64 // milliseconds until the next attempt
+	Error   string
 }
 
-// FragWsInfo is a struct for player-updates over websockets, it has its dedicated type
-type FragWsInfo struct {
-	Type string    `json:"type"`
-	Frag *FragInfo `json:"frag"`
+// DetectionInfo is a struct containing a rule that matched a player and why, DetectedAt is a unix timestamp
+type DetectionInfo struct {
+	Rule       string
+	Kind       string
+	SteamID    int64 `json:"SteamID,string"`
+	Name       string
+	Evidence   string
+	DetectedAt int64
 }
 
 // ChatInfo is a struct containing all the info we need about a chat message
@@ -92,6 +227,23 @@ type FragInfo struct {
 	Crit          bool
 }
 
+// SuicideInfo is a struct containing all the info we need about a suicide
+type SuicideInfo struct {
+	PlayerName string
+	SteamID    string
+}
+
+// IsMarkAttribute reports whether players can be marked with the given attribute
+func IsMarkAttribute(attribute string) bool {
+	for _, known := range MarkAttributes {
+		if known == attribute {
+			return true
+		}
+	}
+
+	return false
+}
+
 // LobbyDebugPlayer is a struct holding all the fields that come with tf_lobby_debug response
 type LobbyDebugPlayer struct {
 	MemberType string
@@ -126,6 +278,37 @@ func GrokInit() {
 	gFrag, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
 	gcFrag, _ = gFrag.Compile(grokFragPattern)
 
+	// Compile the suicide grok pattern
+	gSuicide, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcSuicide, _ = gSuicide.Compile(grokSuicidePattern)
+
+	// Compile the map grok pattern
+	gMap, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcMap, _ = gMap.Compile(grokMapPattern)
+
+	// Compile the server banner grok patterns
+	gConnecting, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcConnecting, _ = gConnecting.Compile(grokConnectingPattern)
+
+	gDisconnect, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcDisconnect, _ = gDisconnect.Compile(grokDisconnectPattern)
+
+	gAddress, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcAddress, _ = gAddress.Compile(grokAddressPattern)
+
+	gHostname, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcHostname, _ = gHostname.Compile(grokHostnamePattern)
+
+	gTags, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcTags, _ = gTags.Compile(grokTagsPattern)
+
+	gServerID, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcServerID, _ = gServerID.Compile(grokServerIDPattern)
+
+	// Compile the vote cooldown grok pattern
+	gVoteCooldown, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
+	gcVoteCooldown, _ = gVoteCooldown.Compile(grokVoteCooldownPattern)
+
 	// Compile the lobby grok pattern
 	gLobby, _ = grok.New(grok.Config{NamedCapturesOnly: true, Patterns: GrokDefinitions})
 	gcLobby, _ = gLobby.Compile(grokLobbyPattern)
@@ -247,6 +430,135 @@ func GrokParseFrag(line string) (*FragInfo, error) {
 	return &fragInfo, nil
 }
 
+// GrokParseSuicide parses the given line with the suicide grok pattern
+func GrokParseSuicide(line string) (*SuicideInfo, error) {
+
+	parsed := gcSuicide.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return nil, errors.New("failed to parse suicide line")
+	}
+
+	suicideInfo := SuicideInfo{
+		PlayerName: parsed["player_name"],
+	}
+
+	return &suicideInfo, nil
+}
+
+// GrokParseMap parses the given line with the map grok pattern, it matches the `Map: ` line printed when joining a server and the `map :` line of `status`
+func GrokParseMap(line string) (string, error) {
+
+	parsed := gcMap.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return "", errors.New("failed to parse map line")
+	}
+
+	return parsed["map"], nil
+}
+
+// GrokParseConnecting parses the `Connecting to` line printed when joining a server and returns the server address
+func GrokParseConnecting(line string) (string, error) {
+
+	parsed := gcConnecting.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return "", errors.New("failed to parse connecting line")
+	}
+
+	return parsed["address"], nil
+}
+
+// GrokParseDisconnect parses the line printed when we leave or get dropped from a server and returns the reason, if any
+func GrokParseDisconnect(line string) (string, error) {
+
+	trimmed := TrimCommon(line)
+	if !gcDisconnect.MatchString(trimmed) {
+		return "", errors.New("failed to parse disconnect line")
+	}
+
+	return gcDisconnect.ParseString(trimmed)["reason"], nil
+}
+
+// GrokParseServerAddress parses the `Connected to` line and the `udp/ip :` line of `status` and returns the server address
+func GrokParseServerAddress(line string) (string, error) {
+
+	parsed := gcAddress.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return "", errors.New("failed to parse server address line")
+	}
+
+	return parsed["address"], nil
+}
+
+// GrokParseHostname parses the `hostname:` line of `status`
+func GrokParseHostname(line string) (string, error) {
+
+	parsed := gcHostname.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return "", errors.New("failed to parse hostname line")
+	}
+
+	return parsed["hostname"], nil
+}
+
+// GrokParseTags parses the `tags :` line of `status` and returns the server tags
+func GrokParseTags(line string) ([]string, error) {
+
+	parsed := gcTags.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return nil, errors.New("failed to parse tags line")
+	}
+
+	return strings.Split(parsed["tags"], ","), nil
+}
+
+// GrokParseServerSteamID parses the `steamid :` line of `status` and returns the server steamID64
+func GrokParseServerSteamID(line string) (int64, error) {
+
+	parsed := gcServerID.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return 0, errors.New("failed to parse server steamid line")
+	}
+
+	steamID, err := strconv.ParseInt(parsed["steamID"], 10, 64)
+	if err != nil {
+		return 0, errors.New("failed to parse server steamID")
+	}
+
+	return steamID, nil
+}
+
+// GrokParseVoteCooldown parses the console line of a refused vote and returns how long we have to wait for the next one.
+// The lines are the GameUI_vote_failed_vote_spam and GameUI_vote_failed_recently messages, in seconds or minutes.
+func GrokParseVoteCooldown(line string) (time.Duration, error) {
+
+	parsed := gcVoteCooldown.ParseString(TrimCommon(line))
+
+	if len(parsed) == 0 {
+		return 0, errors.New("failed to parse vote cooldown line")
+	}
+
+	amount, err := strconv.Atoi(parsed["amount"])
+	if err != nil {
+		return 0, errors.New("failed to parse vote cooldown amount")
+	}
+
+	switch parsed["unit"] {
+	case "second", "seconds":
+		return time.Duration(amount) * time.Second, nil
+	case "minute", "minutes":
+		return time.Duration(amount) * time.Minute, nil
+	default:
+		return 0, errors.New("failed to parse vote cooldown unit")
+	}
+}
+
 // GrokParseLobby parses the given line with the lobby grok pattern
 func GrokParseLobby(line string) (LobbyDebugPlayer, error) {
 	parsed := gcLobby.ParseString(line)
@@ -286,16 +598,26 @@ func GrokParseCommand(line string) (string, string, error) {
 	return command, argsTrimmed, nil
 }
 
-// GetSteamIDFromPlayerName gets the steamID64 from a player name cache
+// GetSteamIDFromPlayerName gets the steamID64 from a player name cache, names shared by several players are ambiguous
 func GetSteamIDFromPlayerName(playerName string, playersInfo []*PlayerInfo) (int64, error) {
 
+	var steamID int64
 	for _, playerInfo := range playersInfo {
-		if playerInfo.Name == playerName {
-			return playerInfo.SteamID, nil
+		if playerInfo.Name != playerName {
+			continue
+		}
+
+		if steamID != 0 && steamID != playerInfo.SteamID {
+			return 0, errors.New("Player name is ambiguous")
 		}
+		steamID = playerInfo.SteamID
 	}
 
-	return 0, errors.New("Player not found")
+	if steamID == 0 {
+		return 0, errors.New("Player not found")
+	}
+
+	return steamID, nil
 }
 
 // EmptyLog empties the tf2 log file
@@ -380,6 +702,11 @@ func Steam3IDToSteam64(steam3ID int64) int64 {
 	return num
 }
 
+// Steam64ToSteam3ID converts the steamID64 back into the account ID of the steamID3 [U:1:<id>]
+func Steam64ToSteam3ID(steamID int64) int64 {
+	return steamID - Steam3IDToSteam64(0)
+}
+
 // removeQuotes removes all quotes from a string
 func removeQuotes(str string) string {
 	return strings.ReplaceAll(str, "\"", "")
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrokParseVoteCooldown(t *testing.T) {
+	GrokInit()
+
+	valid := map[string]time.Duration{
+		"Wait 30 seconds before calling another vote.":    30 * time.Second,
+		"Wait 1 second before calling another vote.":      time.Second,
+		"Wait 2 minutes before calling another vote.":     2 * time.Minute,
+		"This vote failed recently. Wait 45 seconds.":     45 * time.Second,
+		"This vote failed recently. Wait 1 minute.":       time.Minute,
+		"Wait 5 seconds before calling another vote.\r\n": 5 * time.Second,
+	}
+
+	for line, want := range valid {
+		got, err := GrokParseVoteCooldown(line)
+		if err != nil || got != want {
+			t.Errorf("GrokParseVoteCooldown(%q) = %s, %v, want %s", line, got, err, want)
+		}
+	}
+
+	// Lines that merely talk about votes and seconds are not ours to retry on
+	invalid := []string{
+		"Vote called 30 seconds ago",
+		"player : vote kick in 5 seconds pls",
+		"Wait 3 days before calling another vote.",
+		"Wait 30 seconds before calling another vote. really",
+		"Voting is not allowed in the first 30 seconds of a round.",
+	}
+
+	for _, line := range invalid {
+		if got, err := GrokParseVoteCooldown(line); err == nil {
+			t.Errorf("GrokParseVoteCooldown(%q) = %s, want an error", line, got)
+		}
+	}
+}
+
+func TestGrokParseSessionLines(t *testing.T) {
+	GrokInit()
+
+	parsers := map[string]func(string) (string, error){
+		"map":        GrokParseMap,
+		"connecting": GrokParseConnecting,
+		"disconnect": GrokParseDisconnect,
+		"address":    GrokParseServerAddress,
+		"hostname":   GrokParseHostname,
+	}
+
+	cases := []struct {
+		parser string
+		line   string
+		want   string
+		ok     bool
+	}{
+		{"map", "Map: cp_snakewater_final1", "cp_snakewater_final1", true},
+		{"map", "map     : workshop/cp_reckoner_rc6.ugc674719999 at: 0 x, 0 y, 0 z", "workshop/cp_reckoner_rc6.ugc674719999", true},
+		{"map", "atomy :  Map: cp_badlands", "", false},
+		{"connecting", "Connecting to 135.125.189.220:27025...", "135.125.189.220:27025", true},
+		{"connecting", "Connecting to 135.125.189.220:27025...\r\n", "135.125.189.220:27025", true},
+		{"connecting", "Connecting to the lobby", "", false},
+		{"disconnect", "Disconnect: Kicked by Console", "Kicked by Console", true},
+		{"disconnect", "Disconnecting from abandoned match server", "", true},
+		{"disconnect", "atomy :  Disconnect: ragequit", "", false},
+		{"address", "Connected to 135.125.189.220:27025", "135.125.189.220:27025", true},
+		{"address", "udp/ip  : 51.195.189.144:27045", "51.195.189.144:27045", true},
+		{"address", "Connected to the internet now", "", false},
+		{"hostname", "hostname: Uncletopia | Frankfurt | 2 | All Maps", "Uncletopia | Frankfurt | 2 | All Maps", true},
+		{"hostname", "atomy :  hostname: fake", "", false},
+	}
+
+	for _, c := range cases {
+		got, err := parsers[c.parser](c.line)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("%s(%q) = %q, %v, want %q", c.parser, c.line, got, err, c.want)
+		}
+	}
+}
+
+func TestGrokParseTags(t *testing.T) {
+	GrokInit()
+
+	tags, err := GrokParseTags("tags    : cp,nocrits,nodmgspread,uncletopia")
+	if err != nil || len(tags) != 4 || tags[0] != "cp" || tags[3] != "uncletopia" {
+		t.Errorf("GrokParseTags() = %q, %v, want the four tags", tags, err)
+	}
+
+	if tags, err := GrokParseTags("tags are cp"); err == nil {
+		t.Errorf("GrokParseTags() = %q, want an error", tags)
+	}
+}
+
+func TestGrokParseServerSteamID(t *testing.T) {
+	GrokInit()
+
+	steamID, err := GrokParseServerSteamID("steamid : [G:1:4430566] (85568392924469990)")
+	if err != nil || steamID != 85568392924469990 {
+		t.Errorf("GrokParseServerSteamID() = %d, %v, want 85568392924469990", steamID, err)
+	}
+
+	for _, line := range []string{"steamid : [U:1:1] (76561197960265729)", "steamid : [G:1:4430566] (gameserver)"} {
+		if steamID, err := GrokParseServerSteamID(line); err == nil {
+			t.Errorf("GrokParseServerSteamID(%q) = %d, want an error", line, steamID)
+		}
+	}
+}
+
+func TestGrokParseSuicide(t *testing.T) {
+	GrokInit()
+
+	valid := map[string]string{
+		"atomy suicided.":       "atomy",
+		"atomy suicided.\r\n":   "atomy",
+		"a suicided. suicided.": "a suicided.",
+	}
+
+	for line, want := range valid {
+		suicide, err := GrokParseSuicide(line)
+		if err != nil || suicide.PlayerName != want {
+			t.Errorf("GrokParseSuicide(%q) = %+v, %v, want %q", line, suicide, err, want)
+		}
+	}
+
+	for _, line := range []string{"atomy suicided", "atomy suicided. again", "atomy killed bob with scattergun."} {
+		if suicide, err := GrokParseSuicide(line); err == nil {
+			t.Errorf("GrokParseSuicide(%q) = %+v, want an error", line, suicide)
+		}
+	}
+}
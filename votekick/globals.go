@@ -0,0 +1,33 @@
+package votekick
+
+import (
+	"errors"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/logger"
+)
+
+// Create a new instance of the logger.
+var log = logger.Logger
+
+const (
+	// reason is sent along with every kick vote
+	reason = "cheating"
+	// maxAttempts is the number of times a vote is called before giving up on the cooldown
+	maxAttempts = 5
+	// cooldownWindow is how long after calling a vote a cooldown message is attributed to it
+	cooldownWindow = 10 * time.Second
+	// retryMargin is added to the cooldown the server told us, its clock is not ours
+	retryMargin = time.Second
+)
+
+var (
+	// ErrUnknownPlayer is returned if the target is not in the player list
+	ErrUnknownPlayer = errors.New("player is not on the server")
+	// ErrNoLobby is returned if tf_lobby_debug did not list us or the target
+	ErrNoLobby = errors.New("player is not in our lobby")
+	// ErrOtherTeam is returned if the target is not on our team, votes only work against team mates
+	ErrOtherTeam = errors.New("player is not on our team")
+	// ErrSelf is returned if the target is the local player
+	ErrSelf = errors.New("refusing to kick ourselves")
+)
@@ -0,0 +1,12 @@
+package votekick
+
+import (
+	"github.com/algo7/tf2_rcon_misc/events"
+)
+
+// Subscribe retries the pending vote of the kicker whenever the server reports a vote cooldown
+func Subscribe(bus *events.Bus, kicker *Kicker) {
+	bus.Subscribe(func(e events.Event) {
+		kicker.Cooldown(e.(events.VoteCooldown).Wait)
+	}, events.TypeVoteCooldown)
+}
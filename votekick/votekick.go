@@ -0,0 +1,151 @@
+// Package votekick calls kick votes against players on our team and retries them while the vote is on cooldown.
+package votekick
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/state"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// Kicker calls kick votes, one target at a time
+type Kicker struct {
+	players *state.PlayerRegistry
+
+	mu       sync.Mutex
+	target   int64
+	attempts int
+	calledAt time.Time
+	retry    *time.Timer
+}
+
+// NewKicker creates a kicker that resolves targets with the given player registry
+func NewKicker(players *state.PlayerRegistry) *Kicker {
+	return &Kicker{players: players}
+}
+
+// Kick calls a kick vote against the player with the given steamID64, it replaces a pending retry for another target
+func (k *Kicker) Kick(steamID int64) error {
+	k.mu.Lock()
+	if k.retry != nil {
+		k.retry.Stop()
+		k.retry = nil
+	}
+
+	k.target = steamID
+	k.attempts = 0
+	k.mu.Unlock()
+
+	if err := k.call(steamID); err != nil {
+		k.forget(steamID)
+		return err
+	}
+
+	return nil
+}
+
+// Cooldown retries the pending vote once the cooldown is over, cooldowns that are not ours are ignored
+func (k *Kicker) Cooldown(wait time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.target == 0 || k.retry != nil || time.Since(k.calledAt) > cooldownWindow {
+		return
+	}
+
+	if k.attempts >= maxAttempts {
+		log.Printf("Giving up kicking %d after %d attempts", k.target, k.attempts)
+		k.target = 0
+		return
+	}
+
+	log.Printf("Vote is on cooldown, kicking %d again in %v", k.target, wait)
+
+	target := k.target
+	k.retry = time.AfterFunc(wait+retryMargin, func() {
+		k.mu.Lock()
+		// Kick was called for someone else in the meantime
+		if k.target != target {
+			k.mu.Unlock()
+			return
+		}
+		k.retry = nil
+		k.mu.Unlock()
+
+		if err := k.call(target); err != nil {
+			log.Printf("Unable to kick %d again: %v", target, err)
+			k.forget(target)
+		}
+	})
+}
+
+// call verifies the target and calls the vote. The lock is only taken once the team is verified,
+// the check waits for RCON and the console must be able to report cooldowns in the meantime.
+func (k *Kicker) call(target int64) error {
+	player, ok := k.players.LookupBySteamID(target)
+	if !ok {
+		return ErrUnknownPlayer
+	}
+
+	if player.IsMe {
+		return ErrSelf
+	}
+
+	if err := k.verifyTeam(player); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Kick was called for someone else while we checked the team
+	if k.target != target {
+		return nil
+	}
+
+	k.attempts++
+	k.calledAt = time.Now()
+
+	log.Printf("Calling kick vote against '%s' (%d), attempt %d", player.Name, player.SteamID, k.attempts)
+	network.RconQueue(fmt.Sprintf(`callvote kick "%d %s"`, player.UserID, reason), network.PriorityHigh)
+
+	return nil
+}
+
+// forget drops the target unless Kick was called for someone else in the meantime
+func (k *Kicker) forget(target int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.target == target {
+		k.target = 0
+	}
+}
+
+// verifyTeam checks with a fresh tf_lobby_debug that the player is on our team
+func (k *Kicker) verifyTeam(player *utils.PlayerInfo) error {
+	me, ok := k.players.Me()
+	if !ok {
+		return ErrNoLobby
+	}
+
+	response := network.RconExecute("tf_lobby_debug")
+	k.players.SetLobbyDebug(response)
+
+	lobbyPlayers := utils.ParseLobbyResponse(response)
+	ourself := utils.FindLobbyPlayerBySteamId(lobbyPlayers, me.SteamID)
+	target := utils.FindLobbyPlayerBySteamId(lobbyPlayers, player.SteamID)
+
+	if ourself == nil || target == nil {
+		return ErrNoLobby
+	}
+
+	if ourself.Team != target.Team {
+		return ErrOtherTeam
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package votekick
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/algo7/tf2_rcon_misc/config"
+	"github.com/algo7/tf2_rcon_misc/events"
+	"github.com/algo7/tf2_rcon_misc/network"
+	"github.com/algo7/tf2_rcon_misc/network/rcontest"
+	"github.com/algo7/tf2_rcon_misc/state"
+	"github.com/algo7/tf2_rcon_misc/utils"
+)
+
+// mySteamID is the local player of every test
+const mySteamID = 76561198000000001
+
+var (
+	connectOnce sync.Once
+	server      *rcontest.Server
+)
+
+// connect starts the RCON server all tests of the package share, the command queue can only be stopped once per process
+func connect(t *testing.T) *rcontest.Server {
+	t.Helper()
+
+	connectOnce.Do(func() {
+		utils.GrokInit()
+
+		server = rcontest.NewServer("secret", "me")
+		network.Configure(config.Rcon{Host: server.Host(), Port: server.Port(), Password: "secret", DialTimeout: config.Duration(time.Second)})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := network.Connect(ctx); err != nil {
+			t.Fatalf("Connect() = %v", err)
+		}
+	})
+
+	return server
+}
+
+// lobby returns a tf_lobby_debug response listing the players with their teams
+func lobby(teams map[int64]string) string {
+	response := "CTFLobbyShared: ID:00021f2e3d4c5b6a  6 member(s), 0 pending\n"
+
+	i := 0
+	for steamID, team := range teams {
+		response += fmt.Sprintf("  Member[%d] [U:1:%d]  team = %s  type = MATCH_PLAYER\n", i, utils.Steam64ToSteam3ID(steamID), team)
+		i++
+	}
+
+	return response
+}
+
+// newKicker returns a kicker with us and the given targets on the server, the user ID of a target is its index plus userID
+func newKicker(userID int, targets ...int64) *Kicker {
+	players := state.NewPlayerRegistry()
+	players.Upsert(&utils.PlayerInfo{SteamID: mySteamID, UserID: 1, Name: "me", LastSeen: time.Now().Unix()})
+	players.SetMe(mySteamID)
+
+	for i, steamID := range targets {
+		players.Upsert(&utils.PlayerInfo{SteamID: steamID, UserID: userID + i, Name: fmt.Sprintf("target %d", i), LastSeen: time.Now().Unix()})
+	}
+
+	return NewKicker(players)
+}
+
+// votes counts the kick votes the server received against the user ID
+func votes(server *rcontest.Server, userID int) int {
+	command := fmt.Sprintf(`callvote kick "%d %s"`, userID, reason)
+
+	count := 0
+	for _, received := range server.Commands() {
+		if received == command {
+			count++
+		}
+	}
+
+	return count
+}
+
+// waitForVotes waits until the server received n kick votes against the user ID
+func waitForVotes(t *testing.T, server *rcontest.Server, userID int, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if votes(server, userID) >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the server received %d kick votes against #%d, want %d: %q", votes(server, userID), userID, n, server.Commands())
+}
+
+func TestTeamCheck(t *testing.T) {
+	server := connect(t)
+
+	const (
+		mate     = 76561198000000101
+		opponent = 76561198000000102
+		stranger = 76561198000000103
+	)
+
+	server.Respond("tf_lobby_debug", lobby(map[int64]string{
+		mySteamID: "TF_GC_TEAM_DEFENDERS",
+		mate:      "TF_GC_TEAM_DEFENDERS",
+		opponent:  "TF_GC_TEAM_INVADERS",
+	}))
+
+	cases := []struct {
+		name    string
+		steamID int64
+		err     error
+	}{
+		{"team mate", mate, nil},
+		{"other team", opponent, ErrOtherTeam},
+		{"not in the lobby", stranger, ErrNoLobby},
+		{"not on the server", 76561198000000199, ErrUnknownPlayer},
+		{"ourselves", mySteamID, ErrSelf},
+	}
+
+	kicker := newKicker(100, mate, opponent, stranger)
+	for _, c := range cases {
+		if err := kicker.Kick(c.steamID); err != c.err {
+			t.Errorf("%s: Kick() = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	waitForVotes(t, server, 100, 1)
+	if votes(server, 101) != 0 || votes(server, 102) != 0 || votes(server, 1) != 0 {
+		t.Fatalf("votes were called against players off our team: %q", server.Commands())
+	}
+
+	// Outside a matchmaking lobby the team is unknown
+	server.Respond("tf_lobby_debug", "Failed to find lobby shared object")
+	if err := newKicker(110, mate).Kick(mate); err != ErrNoLobby {
+		t.Errorf("Kick() without a lobby = %v, want %v", err, ErrNoLobby)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	server := connect(t)
+
+	const target = 76561198000000201
+	server.Respond("tf_lobby_debug", lobby(map[int64]string{mySteamID: "TF_GC_TEAM_DEFENDERS", target: "TF_GC_TEAM_DEFENDERS"}))
+
+	kicker := newKicker(200, target)
+	if err := kicker.Kick(target); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cooldowns long after our vote belong to someone else's
+	kicker.mu.Lock()
+	kicker.calledAt = time.Now().Add(-2 * cooldownWindow)
+	kicker.mu.Unlock()
+
+	kicker.Cooldown(time.Minute)
+	if kicker.retry != nil {
+		t.Fatal("a cooldown outside the window scheduled a retry")
+	}
+
+	// The last attempt gives up instead of retrying
+	kicker.mu.Lock()
+	kicker.calledAt = time.Now()
+	kicker.attempts = maxAttempts
+	kicker.mu.Unlock()
+
+	kicker.Cooldown(time.Minute)
+	if kicker.retry != nil || kicker.target != 0 {
+		t.Fatalf("the kicker still retries #%d after %d attempts", kicker.target, maxAttempts)
+	}
+
+	// Without a target nothing is retried
+	kicker.Cooldown(time.Minute)
+	if kicker.retry != nil {
+		t.Fatal("a cooldown without a target scheduled a retry")
+	}
+}
+
+func TestKickReplacesRetry(t *testing.T) {
+	server := connect(t)
+
+	const (
+		first  = 76561198000000301
+		second = 76561198000000302
+	)
+	server.Respond("tf_lobby_debug", lobby(map[int64]string{mySteamID: "TF_GC_TEAM_DEFENDERS", first: "TF_GC_TEAM_DEFENDERS", second: "TF_GC_TEAM_DEFENDERS"}))
+
+	kicker := newKicker(300, first, second)
+	if err := kicker.Kick(first); err != nil {
+		t.Fatal(err)
+	}
+
+	kicker.Cooldown(0)
+	if kicker.retry == nil {
+		t.Fatal("the cooldown scheduled no retry")
+	}
+
+	if err := kicker.Kick(second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry of the first target would have fired by now
+	time.Sleep(retryMargin + 500*time.Millisecond)
+
+	waitForVotes(t, server, 301, 1)
+	if n := votes(server, 300); n != 1 {
+		t.Fatalf("%d votes against the replaced target, want only the first one", n)
+	}
+}
+
+// TestRetryAfterCooldown calls a vote, reports the cooldown like the console does and expects a single retry
+func TestRetryAfterCooldown(t *testing.T) {
+	server := connect(t)
+
+	const target = 76561198000000401
+	server.Respond("tf_lobby_debug", lobby(map[int64]string{mySteamID: "TF_GC_TEAM_INVADERS", target: "TF_GC_TEAM_INVADERS"}))
+
+	kicker := newKicker(400, target)
+	bus := events.NewBus()
+	Subscribe(bus, kicker)
+
+	if err := kicker.Kick(target); err != nil {
+		t.Fatal(err)
+	}
+	waitForVotes(t, server, 400, 1)
+
+	wait, err := utils.GrokParseVoteCooldown("Wait 1 seconds before calling another vote.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish(events.VoteCooldown{Wait: wait})
+
+	// The same cooldown shows up in the console twice, only one retry may follow
+	bus.Publish(events.VoteCooldown{Wait: wait})
+
+	waitForVotes(t, server, 400, 2)
+	time.Sleep(500 * time.Millisecond)
+
+	if n := votes(server, 400); n != 2 {
+		t.Fatalf("%d kick votes, want the vote and one retry", n)
+	}
+}